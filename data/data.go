@@ -3,16 +3,17 @@ package data
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 
+	"github.com/kencx/sxkcd/metrics"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -21,9 +22,8 @@ var (
 	invalidUnmarshalErr *json.InvalidUnmarshalError
 )
 
-// Fetch all comics latest concurrently.
-// This does not guarantee that comics will be in order.
-func (c *Client) FetchAll() ([]byte, error) {
+// Fetch all comics up to the latest concurrently, sorted by number.
+func (c *Client) FetchAll() ([]Comic, error) {
 	// fetch latest
 	latest, err := c.Fetch(0)
 	if err != nil {
@@ -31,8 +31,32 @@ func (c *Client) FetchAll() ([]byte, error) {
 	}
 	num := latest.Number
 
-	log.Printf("Retrieving %d comics from API", num-1)
+	slog.Info("retrieving comics from API", "count", num-1)
 
+	nums := make([]int, 0, num)
+	for i := 1; i < num+1; i++ {
+		if i != 404 {
+			nums = append(nums, i)
+		}
+	}
+
+	comics := make([]Comic, 0, num)
+	err = c.fetchEach(nums, c.Fetch, func(comic *Comic) error {
+		comics = append(comics, *comic)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(comics, func(i, j int) bool { return comics[i].Number < comics[j].Number })
+	return comics, nil
+}
+
+// fetchEach calls fetch for all nums concurrently and done with each fetched
+// comic. done is never called concurrently. It stops at the first error or
+// when SIGINT or SIGTERM is received.
+func (c *Client) fetchEach(nums []int, fetch func(int) (*Comic, error), done func(*Comic) error) error {
 	var mu sync.Mutex
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
@@ -47,47 +71,38 @@ func (c *Client) FetchAll() ([]byte, error) {
 	}()
 
 	progress := 0
-
-	comics := make(map[int]*Comic, num)
-	for i := 1; i < num+1; i++ {
-		id := i
+	for _, num := range nums {
+		id := num
 
 		g.Go(func() error {
-			if id == 404 {
-				return nil
-			}
-			comic, err := c.Fetch(id)
+			comic, err := fetch(id)
 			if err != nil {
 				return err
 			}
 
 			mu.Lock()
 			defer mu.Unlock()
-			comics[id] = comic
+			if err := done(comic); err != nil {
+				return err
+			}
 
 			progress += 1
+			if progress%200 == 0 {
+				slog.Info("downloaded comics", "progress", progress, "total", len(nums))
+			}
 			return nil
 		})
-
-		if progress%200 == 0 && progress != 0 {
-			log.Printf("Downloaded: %d/%d comics\n", progress, num-1)
-		}
 	}
 
-	err = g.Wait()
+	err := g.Wait()
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			return nil, fmt.Errorf("cancelled due to signal interrupt")
+		if ctx.Err() != nil {
+			return fmt.Errorf("cancelled due to signal interrupt")
 		} else {
-			return nil, err
+			return err
 		}
 	}
-
-	s, err := json.Marshal(comics)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal comics: %v", err)
-	}
-	return s, nil
+	return nil
 }
 
 // Fetch comic by given number
@@ -100,20 +115,16 @@ func (c *Client) Fetch(num int) (*Comic, error) {
 	var xkcd Xkcd
 	err := c.getXkcd(num, &xkcd)
 	if err != nil {
+		metrics.FetchErrorsTotal.WithLabelValues("xkcd").Inc()
 		return nil, fmt.Errorf("failed to get xkcd %d: %w", num, err)
 	}
 
-	explainWiki := struct {
-		Parse struct {
-			Wikitext map[string]string
-		}
-	}{}
-	err = c.getExplain(num, &explainWiki)
+	explanation, err := c.FetchExplanation(xkcd.Number)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get explain %d: %w", num, err)
+		return nil, err
 	}
 	explain := ExplainXkcd{
-		Explanation: extractExplanation(explainWiki.Parse.Wikitext["*"]),
+		Explanation: explanation,
 	}
 
 	comic, err := NewComic(xkcd, explain)
@@ -123,6 +134,21 @@ func (c *Client) Fetch(num int) (*Comic, error) {
 	return comic, nil
 }
 
+// FetchExplanation fetches the explanation of comic num from explainxkcd
+func (c *Client) FetchExplanation(num int) (string, error) {
+	explainWiki := struct {
+		Parse struct {
+			Wikitext map[string]string
+		}
+	}{}
+	err := c.getExplain(num, &explainWiki)
+	if err != nil {
+		metrics.FetchErrorsTotal.WithLabelValues("explainxkcd").Inc()
+		return "", fmt.Errorf("failed to get explain %d: %w", num, err)
+	}
+	return extractExplanation(explainWiki.Parse.Wikitext["*"]), nil
+}
+
 func extractExplanation(wikitext string) string {
 	if wikitext == "" {
 		return ""
@@ -0,0 +1,286 @@
+package data
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Download updates a data file incrementally. Only the comics missing from
+// the file are fetched, and the explanations of comics that were fetched
+// longer than RefreshAge ago. Fetched comics are appended to a checkpoint file
+// as they arrive, so that an interrupted download resumes where it stopped.
+type Download struct {
+	Client *Client
+	// Path of the data file. The checkpoint is written to Path.partial and
+	// the time each comic was last fetched to Path.state.
+	Path string
+	// RefreshAge refetches the explanations of comics fetched longer ago, or
+	// never if 0
+	RefreshAge time.Duration
+
+	// Read returns the comics of the data file, or none if it does not exist
+	Read func() ([]Comic, error)
+	// Write replaces the data file with comics
+	Write func([]Comic) error
+}
+
+// DownloadResult counts the comics of a Download
+type DownloadResult struct {
+	// Resumed comics were fetched by an interrupted download
+	Resumed   int
+	Added     int
+	Refreshed int
+	Total     int
+}
+
+func (d *Download) Run() (*DownloadResult, error) {
+	existing, err := d.Read()
+	if err != nil {
+		return nil, err
+	}
+	comics := make(map[int]*Comic, len(existing))
+	for i := range existing {
+		comics[existing[i].Number] = &existing[i]
+	}
+
+	state, err := readState(d.statePath())
+	if err != nil {
+		return nil, err
+	}
+
+	cp, pending, err := openCheckpoint(d.checkpointPath())
+	if err != nil {
+		return nil, err
+	}
+	defer cp.close()
+
+	res := &DownloadResult{Resumed: len(pending)}
+	resumed := make(map[int]bool, len(pending))
+	for _, e := range pending {
+		c := e.Comic
+		comics[c.Number] = &c
+		state[c.Number] = e.FetchedAt
+		resumed[c.Number] = true
+	}
+	if len(pending) > 0 {
+		slog.Info("resuming interrupted download", "comics", len(pending))
+	}
+
+	latest, err := d.Client.Fetch(0)
+	if err != nil {
+		return nil, err
+	}
+
+	record := func(c *Comic) error {
+		now := time.Now().Unix()
+		comics[c.Number] = c
+		state[c.Number] = now
+		return cp.write(c, now)
+	}
+	if _, ok := comics[latest.Number]; !ok {
+		if err := record(latest); err != nil {
+			return nil, err
+		}
+		res.Added++
+	}
+
+	missing, refresh := plan(comics, state, resumed, latest.Number, time.Now(), d.RefreshAge)
+	slog.Info("retrieving comics from API", "missing", len(missing), "refresh", len(refresh))
+
+	// fetchers read copies, as done writes to comics concurrently
+	stale := make(map[int]Comic, len(refresh))
+	for _, num := range refresh {
+		stale[num] = *comics[num]
+	}
+	fetch := func(num int) (*Comic, error) {
+		c, ok := stale[num]
+		if !ok {
+			return d.Client.Fetch(num)
+		}
+		explanation, err := d.Client.FetchExplanation(num)
+		if err != nil {
+			return nil, err
+		}
+		if explanation != "" {
+			c.Explanation = explanation
+		}
+		return &c, nil
+	}
+
+	err = d.Client.fetchEach(append(missing, refresh...), fetch, func(c *Comic) error {
+		if _, ok := stale[c.Number]; ok {
+			res.Refreshed++
+		} else {
+			res.Added++
+		}
+		return record(c)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w, run download again to resume", err)
+	}
+
+	result := make([]Comic, 0, len(comics))
+	for _, c := range comics {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+	res.Total = len(result)
+
+	if err := d.Write(result); err != nil {
+		return nil, err
+	}
+	if err := writeState(d.statePath(), state); err != nil {
+		return nil, err
+	}
+	if err := cp.remove(); err != nil {
+		return nil, fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return res, nil
+}
+
+func (d *Download) checkpointPath() string {
+	return d.Path + ".partial"
+}
+
+func (d *Download) statePath() string {
+	return d.Path + ".state"
+}
+
+// plan returns the numbers of the comics up to latest that are missing from
+// comics, and of the comics whose explanation was fetched longer than
+// refreshAge ago. Comics in resumed were fetched by an interrupted download.
+func plan(comics map[int]*Comic, fetched map[int]int64, resumed map[int]bool, latest int, now time.Time, refreshAge time.Duration) ([]int, []int) {
+	var missing, refresh []int
+	for num := 1; num <= latest; num++ {
+		// 404 is not a comic
+		if num == 404 || resumed[num] {
+			continue
+		}
+
+		_, ok := comics[num]
+		switch {
+		case !ok:
+			missing = append(missing, num)
+		// comics of files without state have an unknown age
+		case refreshAge > 0 && now.Sub(time.Unix(fetched[num], 0)) > refreshAge:
+			refresh = append(refresh, num)
+		}
+	}
+	return missing, refresh
+}
+
+// checkpointEntry is a line of the checkpoint file
+type checkpointEntry struct {
+	FetchedAt int64 `json:"fetched_at"`
+	Comic     Comic `json:"comic"`
+}
+
+// checkpoint is a file of JSON lines of fetched comics
+type checkpoint struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// openCheckpoint opens the checkpoint at path for appending and returns its
+// entries. A truncated last line of an interrupted write is ignored.
+func openCheckpoint(path string) (*checkpoint, []checkpointEntry, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	var (
+		entries []checkpointEntry
+		valid   int64
+	)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+
+		var e checkpointEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			break
+		}
+		entries = append(entries, e)
+		valid += int64(len(line))
+	}
+
+	// drop the truncated line so that new entries start on a new line
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	return &checkpoint{f: f, enc: json.NewEncoder(f)}, entries, nil
+}
+
+func (cp *checkpoint) write(c *Comic, fetchedAt int64) error {
+	if err := cp.enc.Encode(checkpointEntry{FetchedAt: fetchedAt, Comic: *c}); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (cp *checkpoint) close() error {
+	return cp.f.Close()
+}
+
+func (cp *checkpoint) remove() error {
+	cp.f.Close()
+	return os.Remove(cp.f.Name())
+}
+
+// readState returns the time each comic was last fetched by number, or none if
+// the file at path does not exist
+func readState(path string) (map[int]int64, error) {
+	state := make(map[int]int64)
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read download state: %w", err)
+	}
+
+	var fetched map[string]int64
+	if err := json.Unmarshal(b, &fetched); err != nil {
+		return nil, fmt.Errorf("failed to read download state: %w", err)
+	}
+	for k, v := range fetched {
+		num, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid comic number %q in download state", k)
+		}
+		state[num] = v
+	}
+	return state, nil
+}
+
+func writeState(path string, state map[int]int64) error {
+	err := writeAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(state)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write download state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	day := 24 * time.Hour
+
+	comics := map[int]*Comic{1: {Number: 1}, 2: {Number: 2}, 3: {Number: 3}, 405: {Number: 405}}
+	fetched := map[int]int64{
+		1: now.Add(-10 * day).Unix(),
+		2: now.Add(-1 * day).Unix(),
+		// 3 was downloaded before fetch times were stored
+	}
+	resumed := map[int]bool{5: true}
+
+	tests := []struct {
+		name        string
+		refreshAge  time.Duration
+		wantMissing []int
+		wantRefresh []int
+	}{
+		{"missing", 0, []int{4, 6, 403, 406}, nil},
+		{"refresh", 7 * day, []int{4, 6, 403, 406}, []int{1, 3, 405}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, refresh := plan(comics, fetched, resumed, 406, now, tt.refreshAge)
+
+			// only check the boundaries of the long range of missing comics
+			var got []int
+			for _, num := range missing {
+				if num < 7 || num > 402 {
+					got = append(got, num)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantMissing) {
+				t.Errorf("got missing %v, want %v", got, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(refresh, tt.wantRefresh) {
+				t.Errorf("got refresh %v, want %v", refresh, tt.wantRefresh)
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comics.json.partial")
+
+	cp, entries, err := openCheckpoint(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("got %d entries, want 0", len(entries))
+	}
+	for _, num := range []int{1, 2} {
+		if err := cp.write(&Comic{Number: num}, 100); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	cp.close()
+
+	// interrupted while writing comic 3
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"fetched_at":100,"comic":{"num":3,"tit`)
+	f.Close()
+
+	cp, entries, err = openCheckpoint(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Comic.Number != 2 || entries[1].FetchedAt != 100 {
+		t.Fatalf("got %+v", entries)
+	}
+	if err := cp.write(&Comic{Number: 3}, 200); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	cp.close()
+
+	cp, entries, err = openCheckpoint(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(entries) != 3 || entries[2].Comic.Number != 3 {
+		t.Errorf("got %+v, want truncated line replaced", entries)
+	}
+
+	if err := cp.remove(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected checkpoint to be removed")
+	}
+}
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comics.json.state")
+
+	state, err := readState(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(state) != 0 {
+		t.Errorf("got %v, want empty state", state)
+	}
+
+	want := map[int]int64{1: 100, 353: 200}
+	if err := writeState(path, want); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	got, err := readState(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
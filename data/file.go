@@ -0,0 +1,322 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MetaKey is the key of the optional metadata in a data file, which is not a
+// comic
+const MetaKey = "_meta"
+
+// Stdio is the path of stdin or stdout
+const Stdio = "-"
+
+// Format is the format of a data file
+type Format string
+
+const (
+	// FormatJSON is a JSON object of comics keyed by number
+	FormatJSON Format = "json"
+	// FormatJSONL is JSON Lines of comics sorted by number, which can be read
+	// and written one comic at a time and appended to
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat returns the format named s, or the format of path by its
+// extension if s is empty
+func ParseFormat(s, path string) (Format, error) {
+	switch Format(s) {
+	case FormatJSON, FormatJSONL:
+		return Format(s), nil
+	case "":
+		return FormatOf(path), nil
+	}
+	return "", fmt.Errorf("invalid format %q", s)
+}
+
+// FormatOf returns the format of path by its extension
+func FormatOf(path string) Format {
+	switch filepath.Ext(path) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	}
+	return FormatJSON
+}
+
+// Metadata describes how a data file was exported
+type Metadata struct {
+	Version    string    `json:"version,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+	Count      int       `json:"count"`
+	Latest     int       `json:"latest"`
+}
+
+// NewMetadata returns the metadata of an export of comics
+func NewMetadata(version string, comics []Comic) *Metadata {
+	m := &Metadata{
+		Version:    version,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Count:      len(comics),
+	}
+	for _, c := range comics {
+		m.Latest = max(m.Latest, c.Number)
+	}
+	return m
+}
+
+// Encode writes comics as a JSON object keyed by comic number, the format of
+// the data files read by the server. Comics are sorted by number with one
+// field per line, so that exports of the same data are identical and can be
+// diffed. meta is written first if not nil.
+func Encode(w io.Writer, comics []Comic, meta *Metadata) error {
+	sorted := make([]Comic, len(comics))
+	copy(sorted, comics)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+
+	bw := bufio.NewWriter(w)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("  ", "  ")
+
+	first := true
+	entry := func(key string, v interface{}) error {
+		buf.Reset()
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+
+		sep := ",\n"
+		if first {
+			sep, first = "\n", false
+		}
+		k, _ := json.Marshal(key)
+		bw.WriteString(sep + "  " + string(k) + ": ")
+		_, err := bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		return err
+	}
+
+	bw.WriteString("{")
+	if meta != nil {
+		if err := entry(MetaKey, meta); err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+	}
+	for _, c := range sorted {
+		if err := entry(strconv.Itoa(c.Number), &c); err != nil {
+			return fmt.Errorf("failed to marshal comic %d: %w", c.Number, err)
+		}
+	}
+	if !first {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// EncodeLines writes comics as JSON Lines, one comic per line sorted by
+// number. meta is written first as an object with the single key MetaKey if
+// not nil.
+func EncodeLines(w io.Writer, comics []Comic, meta *Metadata) error {
+	sorted := make([]Comic, len(comics))
+	copy(sorted, comics)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	if meta != nil {
+		if err := enc.Encode(map[string]*Metadata{MetaKey: meta}); err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+	}
+	for _, c := range sorted {
+		if err := enc.Encode(&c); err != nil {
+			return fmt.Errorf("failed to marshal comic %d: %w", c.Number, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// EncodeFormat writes comics with Encode or EncodeLines
+func EncodeFormat(w io.Writer, f Format, comics []Comic, meta *Metadata) error {
+	if f == FormatJSONL {
+		return EncodeLines(w, comics, meta)
+	}
+	return Encode(w, comics, meta)
+}
+
+// WriteFile writes comics to path in format f. The file is replaced
+// atomically so that it is never partially written.
+func WriteFile(path string, f Format, comics []Comic, meta *Metadata) error {
+	err := writeAtomic(path, func(w io.Writer) error {
+		return EncodeFormat(w, f, comics, meta)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadFile reads the comics of a data file in either format with Decode
+func ReadFile(path string) ([]Comic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	comics, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return comics, nil
+}
+
+// Decode reads comics in either format, ignoring metadata. The format is
+// detected from the first line, which is a complete comic or metadata object
+// in JSON Lines. Single comics, as written by download -n, are read as JSON
+// Lines.
+func Decode(r io.Reader) ([]Comic, error) {
+	br := bufio.NewReader(r)
+
+	var (
+		first []byte
+		n     int
+	)
+	for len(bytes.TrimSpace(first)) == 0 {
+		line, err := br.ReadBytes('\n')
+		first, n = line, n+1
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(bytes.TrimSpace(first)) == 0 {
+		return nil, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(first, &obj) == nil {
+		_, isComic := obj["num"]
+		_, isMeta := obj[MetaKey]
+		if isComic || (isMeta && len(obj) == 1) {
+			return decodeLines(first, n, br)
+		}
+	}
+	return decodeObject(io.MultiReader(bytes.NewReader(first), br))
+}
+
+// line is a line of a JSON Lines data file, which is either a comic or
+// metadata
+type line struct {
+	Comic
+	Meta *Metadata `json:"_meta"`
+}
+
+// decodeLines reads JSON Lines from br, of which first is line n
+func decodeLines(first []byte, n int, br *bufio.Reader) ([]Comic, error) {
+	var comics []Comic
+	b := first
+	for {
+		if len(bytes.TrimSpace(b)) > 0 {
+			var l line
+			if err := json.Unmarshal(b, &l); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			if l.Meta == nil {
+				if l.Number == 0 {
+					return nil, fmt.Errorf("line %d: missing comic number", n)
+				}
+				comics = append(comics, l.Comic)
+			}
+		}
+
+		var err error
+		b, err = br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(b) == 0 {
+			return comics, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		n++
+	}
+}
+
+// decodeObject reads a JSON object of comics keyed by number one comic at a
+// time
+func decodeObject(r io.Reader) ([]Comic, error) {
+	dec := json.NewDecoder(r)
+
+	t, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("token err: %v", err)
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return nil, fmt.Errorf("not json object")
+	}
+
+	var comics []Comic
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("key err: %v", err)
+		}
+
+		// metadata of exports
+		if key == MetaKey {
+			var meta Metadata
+			if err := dec.Decode(&meta); err != nil {
+				return nil, fmt.Errorf("decode err: %v", err)
+			}
+			continue
+		}
+
+		var val Comic
+		if err := dec.Decode(&val); err != nil {
+			return nil, fmt.Errorf("decode err: %v", err)
+		}
+		comics = append(comics, val)
+	}
+	return comics, nil
+}
+
+// writeAtomic writes a temporary file with write and renames it to path
+func writeAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,245 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	comics := []Comic{
+		{Title: "Ten", Number: 10, Alt: "<b> & </b>"},
+		{Title: "Two", Number: 2},
+	}
+
+	t.Run("sorted", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, comics, nil); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		want := `{
+  "2": {
+    "title": "Two",
+    "num": 2,
+    "img_url": "",
+    "explanation": "",
+    "date": 0
+  },
+  "10": {
+    "title": "Ten",
+    "num": 10,
+    "alt": "<b> & </b>",
+    "img_url": "",
+    "explanation": "",
+    "date": 0
+  }
+}
+`
+		if got := buf.String(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, comics, NewMetadata("v1", comics)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var got map[string]json.RawMessage
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		var meta Metadata
+		if err := json.Unmarshal(got[MetaKey], &meta); err != nil {
+			t.Fatalf("invalid metadata: %v", err)
+		}
+		if meta.Count != 2 || meta.Latest != 10 || meta.Version != "v1" {
+			t.Errorf("got %+v", meta)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, nil, nil); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if got := buf.String(); got != "{}\n" {
+			t.Errorf("got %q, want %q", got, "{}\n")
+		}
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comics.json")
+	if err := os.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, FormatJSON, []Comic{{Title: "Two", Number: 2}}, nil); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]Comic
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if got["2"].Title != "Two" {
+		t.Errorf("got %+v", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want temporary file removed", len(entries))
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	comics := []Comic{{Title: "Ten", Number: 10}, {Title: "Two", Number: 2}}
+
+	for _, f := range []Format{FormatJSON, FormatJSONL} {
+		t.Run(string(f), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "comics."+string(f))
+			if err := WriteFile(path, f, comics, NewMetadata("v1", comics)); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			got, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(got) != 2 {
+				t.Errorf("got %d comics, want 2 without metadata", len(got))
+			}
+		})
+	}
+
+	if _, err := ReadFile(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("got err %v, want not exist", err)
+	}
+}
+
+func TestEncodeLines(t *testing.T) {
+	comics := []Comic{
+		{Title: "Ten", Number: 10, Alt: "<b>"},
+		{Title: "Two", Number: 2},
+	}
+	meta := &Metadata{Version: "v1", Count: 2, Latest: 10}
+
+	var buf bytes.Buffer
+	if err := EncodeLines(&buf, comics, meta); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := `{"_meta":{"version":"v1","exported_at":"0001-01-01T00:00:00Z","count":2,"latest":10}}
+{"title":"Two","num":2,"img_url":"","explanation":"","date":0}
+{"title":"Ten","num":10,"alt":"<b>","img_url":"","explanation":"","date":0}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:  "object",
+			input: "{\n  \"_meta\": {\"count\": 2},\n  \"2\": {\"num\": 2},\n  \"10\": {\"num\": 10}\n}\n",
+			want:  []int{2, 10},
+		},
+		{
+			name:  "compact object",
+			input: `{"2":{"num":2},"10":{"num":10}}`,
+			want:  []int{2, 10},
+		},
+		{
+			name:  "lines",
+			input: "{\"_meta\":{\"count\":2}}\n{\"num\":2}\n\n{\"num\":10}",
+			want:  []int{2, 10},
+		},
+		{
+			name:  "single comic",
+			input: `{"title":"Two","num":2}`,
+			want:  []int{2},
+		},
+		{
+			name:  "empty",
+			input: "\n",
+		},
+		{
+			name:    "invalid line",
+			input:   "{\"num\":2}\n{\"num\":",
+			wantErr: true,
+		},
+		{
+			name:    "line without number",
+			input:   "{\"num\":2}\n{\"title\":\"Ten\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "not an object",
+			input:   `[{"num":2}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comics, err := Decode(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want err %v", err, tt.wantErr)
+			}
+
+			var got []int
+			for _, c := range comics {
+				got = append(got, c.Number)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		path    string
+		want    Format
+		wantErr bool
+	}{
+		{"json extension", "", "comics.json", FormatJSON, false},
+		{"jsonl extension", "", "comics.jsonl", FormatJSONL, false},
+		{"ndjson extension", "", "comics.ndjson", FormatJSONL, false},
+		{"stdout", "", Stdio, FormatJSON, false},
+		{"explicit", "jsonl", "comics.json", FormatJSONL, false},
+		{"invalid", "yaml", "comics.json", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormat(tt.format, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want err %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package data
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -35,6 +38,19 @@ type Comic struct {
 	Date        int64  `json:"date"`
 }
 
+// Hash returns a hash of the content of c to detect changed comics
+func (c *Comic) Hash() string {
+	h := sha256.New()
+	for _, v := range []string{
+		strconv.Itoa(c.Number), c.Title, c.Alt, c.Transcript, c.ImgUrl, c.Explanation,
+		strconv.FormatInt(c.Date, 10),
+	} {
+		// length-prefixed to separate fields unambiguously
+		fmt.Fprintf(h, "%d:%s", len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func NewComic(x Xkcd, e ExplainXkcd) (*Comic, error) {
 
 	// TODO better way to check leading 0 in day and month
@@ -67,3 +67,23 @@ func TestNewComic(t *testing.T) {
 		}
 	})
 }
+
+func TestHash(t *testing.T) {
+	c := Comic{Number: 1, Title: "Barrel", Alt: "Don't we all."}
+	same := c
+	if c.Hash() != same.Hash() {
+		t.Errorf("expected equal hashes")
+	}
+
+	// fields are separated unambiguously
+	moved := Comic{Number: 1, Title: "BarrelDon't we all."}
+	if c.Hash() == moved.Hash() {
+		t.Errorf("expected different hashes")
+	}
+
+	changed := c
+	changed.Date = 1136073600
+	if c.Hash() == changed.Hash() {
+		t.Errorf("expected different hashes")
+	}
+}
@@ -0,0 +1,133 @@
+// Package embed computes vector embeddings of comics and queries for semantic
+// search. Comics are similar to a query if the cosine similarity of their
+// embeddings is high.
+package embed
+
+import (
+	"math"
+	"strings"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/store/query"
+)
+
+// DefaultDim is the number of dimensions of the default embedder
+const DefaultDim = 512
+
+// Embedder computes embeddings of a fixed number of dimensions
+type Embedder interface {
+	// Dim returns the number of dimensions of all embeddings
+	Dim() int
+	// Embed returns the L2-normalized embedding of text, or a zero vector if
+	// text has no terms
+	Embed(text string) []float32
+}
+
+// Default returns the embedder used if none is configured
+func Default() Embedder {
+	return NewHashed(DefaultDim)
+}
+
+// Hashed embeds text with feature hashing of its terms, term bigrams and
+// character trigrams. It runs locally without a model, and matches related
+// word forms such as "marry" and "married" through shared trigrams, but not
+// synonyms.
+type Hashed struct {
+	dim int
+}
+
+func NewHashed(dim int) *Hashed {
+	return &Hashed{dim: dim}
+}
+
+func (h *Hashed) Dim() int {
+	return h.dim
+}
+
+// feature weights
+const (
+	termWeight    = 1.0
+	bigramWeight  = 0.5
+	trigramWeight = 1.0
+)
+
+func (h *Hashed) Embed(text string) []float32 {
+	vec := make([]float64, h.dim)
+	add := func(feature string, weight float64) {
+		sum := hash(feature)
+
+		// the sign bit reduces the bias of hash collisions
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vec[sum%uint64(h.dim)] += weight
+	}
+
+	terms := query.Tokenize(text)
+	for i, t := range terms {
+		add("w:"+t, termWeight)
+		if i > 0 {
+			add("b:"+terms[i-1]+" "+t, bigramWeight)
+		}
+
+		padded := []rune("^" + t + "$")
+		for j := 0; j+3 <= len(padded); j++ {
+			add("c:"+string(padded[j:j+3]), trigramWeight)
+		}
+	}
+	return normalize(vec)
+}
+
+// hash returns the 64-bit FNV-1a hash of s without allocating
+func hash(s string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime
+	}
+	return h
+}
+
+// normalize returns the L2-normalized float32 vector of vec. Components are
+// dampened first so that frequent terms do not dominate long texts.
+func normalize(vec []float64) []float32 {
+	var norm float64
+	for i, v := range vec {
+		if v != 0 {
+			v = math.Copysign(math.Log1p(math.Abs(v)), v)
+			vec[i] = v
+		}
+		norm += v * v
+	}
+
+	out := make([]float32, len(vec))
+	if norm == 0 {
+		return out
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range vec {
+		out[i] = float32(v / norm)
+	}
+	return out
+}
+
+// ComicText returns the text of c that is embedded. The explanation is
+// included as it often describes the topic of a comic in other words.
+func ComicText(c *data.Comic) string {
+	return strings.Join([]string{c.Title, c.Alt, c.Transcript, c.Explanation}, "\n")
+}
+
+// Cosine returns the cosine similarity of the normalized embeddings a and b
+func Cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		if i < len(b) {
+			dot += float64(a[i]) * float64(b[i])
+		}
+	}
+	return dot
+}
@@ -0,0 +1,48 @@
+package embed
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHashed(t *testing.T) {
+	e := NewHashed(DefaultDim)
+
+	vec := e.Embed("Sudo make me a sandwich")
+	if len(vec) != DefaultDim {
+		t.Fatalf("got %d dimensions, want %d", len(vec), DefaultDim)
+	}
+	if n := Cosine(vec, vec); math.Abs(n-1) > 1e-5 {
+		t.Errorf("got norm %f, want 1", n)
+	}
+
+	empty := e.Embed("the of a")
+	if Cosine(empty, empty) != 0 {
+		t.Errorf("expected zero vector for stop words")
+	}
+}
+
+func TestHashedSimilarity(t *testing.T) {
+	e := Default()
+
+	tests := []struct {
+		name    string
+		query   string
+		similar string
+		other   string
+	}{
+		{"same terms", "python programming", "I wrote programs in Python", "a cat sleeps on the keyboard"},
+		{"word forms", "getting married", "marriage proposal", "computer security exploits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := e.Embed(tt.query)
+			similar := Cosine(q, e.Embed(tt.similar))
+			other := Cosine(q, e.Embed(tt.other))
+			if similar <= other {
+				t.Errorf("got similarity %f for %q, want more than %f for %q", similar, tt.similar, other, tt.other)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kencx/sxkcd/data"
+)
+
+const maxAdminBodySize = 1024 * 1024
+
+// fields of a comic that may be edited by admins
+var editableFields = map[string]bool{
+	"explanation": true,
+	"transcript":  true,
+}
+
+// adminHandler serves the /admin route group. All requests must be
+// authenticated with the admin token as a bearer token.
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/reindex", s.adminReindexHandler)
+	mux.HandleFunc("/admin/merge", s.adminReindexHandler)
+	mux.HandleFunc("/admin/fetch", s.adminFetchHandler)
+	mux.HandleFunc("/admin/comics/", s.adminComicHandler)
+	mux.HandleFunc("/admin/jobs", s.adminJobsHandler)
+	mux.HandleFunc("/admin/jobs/", s.adminJobsHandler)
+	mux.HandleFunc("/admin/analytics", s.adminAnalyticsHandler)
+	mux.HandleFunc("/admin/export", s.adminExportHandler)
+
+	return s.requireToken(mux)
+}
+
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sxkcd"`)
+			errorResponse(w, &apiError{
+				Status:  http.StatusUnauthorized,
+				Code:    codeUnauthorized,
+				Message: "invalid or missing admin token",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// POST /admin/reindex {"file": "path or url"}
+// POST /admin/merge {"file": "path or url"}
+func (s *Server) adminReindexHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var body struct {
+		File string `json:"file"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	if body.File == "" {
+		errorResponse(w, errBadRequest("file required"))
+		return
+	}
+	if body.File == data.Stdio {
+		errorResponse(w, errBadRequest("file cannot be read from stdin"))
+		return
+	}
+
+	// reindexes and merges are index jobs, as they must not run concurrently
+	merge := r.URL.Path == "/admin/merge"
+	j, err := s.jobs.start(r.Context(), "index", func(ctx context.Context, progress func(string, ...interface{})) error {
+		if merge {
+			return s.merge(ctx, body.File, progress)
+		}
+		return s.initialize(ctx, body.File, true, progress)
+	})
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// POST /admin/fetch
+func (s *Server) adminFetchHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	j, err := s.jobs.start(r.Context(), "fetch", func(ctx context.Context, progress func(string, ...interface{})) error {
+		progress("Fetching latest comic")
+		return s.worker.FetchNow(ctx)
+	})
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// POST   /admin/comics/{num}/refetch
+// PATCH  /admin/comics/{num} {"explanation": "...", "transcript": "..."}
+// DELETE /admin/comics/{num}
+func (s *Server) adminComicHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/comics/"), "/")
+	parts := strings.Split(path, "/")
+
+	num, err := strconv.Atoi(parts[0])
+	if err != nil || num <= 0 {
+		errorResponse(w, errBadRequest("invalid comic number %q", parts[0]))
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "refetch":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		// refetches of different comics run concurrently
+		j, err := s.jobs.start(r.Context(), "refetch:"+strconv.Itoa(num), func(ctx context.Context, progress func(string, ...interface{})) error {
+			progress("Fetching comic #%d", num)
+			return s.worker.Refetch(ctx, num)
+		})
+		if err != nil {
+			errorResponse(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, j)
+
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodPatch:
+			s.adminPatchComic(w, r, num)
+		case http.MethodDelete:
+			if err := s.store.Delete(r.Context(), num); err != nil {
+				errorResponse(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			allowMethod(w, r, http.MethodPatch, http.MethodDelete)
+		}
+
+	default:
+		errorResponse(w, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    codeNotFound,
+			Message: "not found",
+		})
+	}
+}
+
+func (s *Server) adminPatchComic(w http.ResponseWriter, r *http.Request, num int) {
+	var fields map[string]string
+	if err := decodeBody(w, r, &fields); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	if len(fields) == 0 {
+		errorResponse(w, errBadRequest("no fields provided"))
+		return
+	}
+	for f := range fields {
+		if !editableFields[f] {
+			errorResponse(w, errBadRequest("field %q cannot be edited", f))
+			return
+		}
+	}
+
+	if err := s.store.Patch(r.Context(), num, fields); err != nil {
+		errorResponse(w, err)
+		return
+	}
+
+	comic, err := s.store.Get(r.Context(), num)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, comic)
+}
+
+// GET /admin/export?metadata=true&format=json
+func (s *Server) adminExportHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	format, err := data.ParseFormat(r.URL.Query().Get("format"), "")
+	if err != nil {
+		errorResponse(w, errBadRequest("%v", err))
+		return
+	}
+
+	withMeta := false
+	if v := r.URL.Query().Get("metadata"); v != "" {
+		var err error
+		if withMeta, err = strconv.ParseBool(v); err != nil {
+			errorResponse(w, errBadRequest("invalid metadata %q", v))
+			return
+		}
+	}
+
+	comics, err := s.store.All(r.Context())
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	var meta *data.Metadata
+	if withMeta {
+		meta = data.NewMetadata(s.Version, comics)
+	}
+
+	// encode before writing the headers so that errors can be returned
+	var buf bytes.Buffer
+	if err := data.EncodeFormat(&buf, format, comics, meta); err != nil {
+		errorResponse(w, err)
+		return
+	}
+	contentType := "application/json"
+	if format == data.FormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="comics.%s"`, format))
+	w.Write(buf.Bytes())
+}
+
+// GET /admin/jobs
+// GET /admin/jobs/{id}
+func (s *Server) adminJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/jobs"), "/")
+	if path == "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"jobs": s.jobs.list(),
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(path)
+	if err != nil {
+		errorResponse(w, errBadRequest("invalid job id %q", path))
+		return
+	}
+
+	j, ok := s.jobs.get(id)
+	if !ok {
+		errorResponse(w, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    codeNotFound,
+			Message: "job not found",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// allowMethod writes a 405 response if the request method is not one of
+// methods
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	errorResponse(w, &apiError{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    codeBadRequest,
+		Message: "method not allowed",
+	})
+	return false
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, dest interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dest); err != nil {
+		return errBadRequest("invalid request body: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+)
+
+func TestRequireToken(t *testing.T) {
+	s := &Server{AdminToken: "secret"}
+	h := s.requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer secret", http.StatusOK},
+		{"invalid token", "Bearer foo", http.StatusUnauthorized},
+		{"missing scheme", "secret", http.StatusUnauthorized},
+		{"missing header", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/admin/fetch", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Errorf("got %v, want %v", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobs(t *testing.T) {
+	js := newJobs()
+	release := make(chan struct{})
+
+	// jobs outlive the context of the request that started them
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	j, err := js.start(ctx, "reindex", func(ctx context.Context, progress func(string, ...interface{})) error {
+		progress("indexing %d comics", 10)
+		<-release
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.New("foo")
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if j.Status != jobRunning {
+		t.Errorf("got %v, want %v", j.Status, jobRunning)
+	}
+
+	// only one job of each type may run
+	if _, err := js.start(ctx, "reindex", func(context.Context, func(string, ...interface{})) error { return nil }); err == nil {
+		t.Errorf("expected err: job already running")
+	}
+
+	close(release)
+	waitFor(t, func() bool {
+		got, _ := js.get(j.ID)
+		return got.Status != jobRunning
+	})
+
+	got, ok := js.get(j.ID)
+	if !ok {
+		t.Fatalf("job %d not found", j.ID)
+	}
+	if got.Status != jobFailed || got.Error != "foo" || got.Progress != "indexing 10 comics" {
+		t.Errorf("got %+v", got)
+	}
+	if len(js.list()) != 1 {
+		t.Errorf("got %d jobs, want %d", len(js.list()), 1)
+	}
+}
+
+// blockingStore is a fakeStore whose CheckIndex blocks until release is
+// closed
+type blockingStore struct {
+	fakeStore
+	release chan struct{}
+}
+
+func (b *blockingStore) CheckIndex(context.Context) (bool, error) {
+	<-b.release
+	return false, errors.New("foo")
+}
+
+func TestAdminIndexJobs(t *testing.T) {
+	st := &blockingStore{release: make(chan struct{})}
+	s := &Server{store: st, jobs: newJobs()}
+
+	post := func(target string) int {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"file": "comics.json"}`))
+		s.adminReindexHandler(rec, r)
+		return rec.Code
+	}
+
+	if code := post("/admin/merge"); code != http.StatusAccepted {
+		t.Fatalf("got %v, want %v", code, http.StatusAccepted)
+	}
+	// reindexes and merges of the same index exclude each other
+	if code := post("/admin/reindex"); code != http.StatusConflict {
+		t.Errorf("got %v, want %v", code, http.StatusConflict)
+	}
+	close(st.release)
+}
+
+// exportStore is a fakeStore that returns comics from All
+type exportStore struct {
+	fakeStore
+	comics []data.Comic
+}
+
+func (e *exportStore) All(context.Context) ([]data.Comic, error) {
+	return e.comics, nil
+}
+
+func TestAdminExport(t *testing.T) {
+	s := &Server{
+		Version: "v1",
+		store:   &exportStore{comics: []data.Comic{{Title: "Ten", Number: 10}, {Title: "Two", Number: 2}}},
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		want     int
+		wantMeta bool
+	}{
+		{"comics", "/admin/export", http.StatusOK, false},
+		{"metadata", "/admin/export?metadata=true", http.StatusOK, true},
+		{"invalid metadata", "/admin/export?metadata=foo", http.StatusBadRequest, false},
+		{"invalid format", "/admin/export?format=yaml", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.adminExportHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("got %v, want %v", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var got map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if _, ok := got[data.MetaKey]; ok != tt.wantMeta {
+				t.Errorf("got metadata %v, want %v", ok, tt.wantMeta)
+			}
+			if strings.Index(rec.Body.String(), `"2"`) > strings.Index(rec.Body.String(), `"10"`) {
+				t.Errorf("expected comics sorted by number")
+			}
+		})
+	}
+}
+
+func TestAdminExportLines(t *testing.T) {
+	s := &Server{
+		store: &exportStore{comics: []data.Comic{{Title: "Ten", Number: 10}, {Title: "Two", Number: 2}}},
+	}
+
+	rec := httptest.NewRecorder()
+	s.adminExportHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/export?format=jsonl", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %v, want %v", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("got content type %q", got)
+	}
+
+	comics, err := data.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(comics) != 2 || comics[0].Number != 2 {
+		t.Errorf("got %+v, want comics sorted by number", comics)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for condition")
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kencx/sxkcd/store"
+)
+
+const (
+	analyticsTimeout = 2 * time.Second
+	// longest query stored in the analytics
+	maxAnalyticsQueryLength = 128
+
+	defaultAnalyticsHours = 24
+	defaultAnalyticsLimit = 10
+	maxAnalyticsLimit     = 100
+)
+
+// normalizeQuery lowercases query and collapses whitespace so that
+// equivalent queries are counted together
+func normalizeQuery(query string) string {
+	q := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if len(q) > maxAnalyticsQueryLength {
+		q = strings.ToValidUTF8(q[:maxAnalyticsQueryLength], "")
+	}
+	return q
+}
+
+// recordQuery stores a search query in the analytics without blocking the
+// request. It is a no-op if AnalyticsRetention is 0 or the store does not
+// support analytics.
+func (s *Server) recordQuery(r *http.Request, query string, count int64, d time.Duration) {
+	if _, ok := s.store.(store.Analytics); !ok || s.AnalyticsRetention <= 0 {
+		return
+	}
+
+	query = normalizeQuery(query)
+	if query == "" {
+		return
+	}
+
+	// the request context is cancelled once the response is written
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+		defer cancel()
+
+		a := s.store.(store.Analytics)
+		if err := a.RecordQuery(ctx, query, count, d, s.AnalyticsRetention); err != nil {
+			slog.WarnContext(ctx, "failed to record query analytics", "err", err)
+		}
+	}()
+}
+
+// GET /admin/analytics?hours=24&limit=10
+func (s *Server) adminAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	a, ok := s.store.(store.Analytics)
+	if !ok || s.AnalyticsRetention <= 0 {
+		errorResponse(w, &apiError{
+			Status:  http.StatusNotFound,
+			Code:    codeNotFound,
+			Message: "analytics are disabled",
+		})
+		return
+	}
+
+	maxHours := int(s.AnalyticsRetention / time.Hour)
+	if maxHours < 1 {
+		maxHours = 1
+	}
+	hours, err := intParam(r, "hours", defaultAnalyticsHours, 1, maxHours)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	limit, err := intParam(r, "limit", defaultAnalyticsLimit, 1, maxAnalyticsLimit)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+
+	top, err := a.TopQueries(r.Context(), hours, limit, false)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	zero, err := a.TopQueries(r.Context(), hours, limit, true)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+	hourly, err := a.QueriesPerHour(r.Context(), hours)
+	if err != nil {
+		errorResponse(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"hours":                   hours,
+		"top_queries":             top,
+		"top_zero_result_queries": zero,
+		"hourly":                  hourly,
+	})
+}
+
+// intParam parses the query parameter name as an integer in [lo, hi]. def is
+// returned if the parameter is missing and is capped to hi.
+func intParam(r *http.Request, name string, def, lo, hi int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		if def > hi {
+			def = hi
+		}
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < lo || i > hi {
+		return 0, errBadRequest("%s must be an integer between %d and %d", name, lo, hi)
+	}
+	return i, nil
+}
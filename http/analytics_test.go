@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"lowercase", "Python", "python"},
+		{"whitespace", "  hello \t  world \n", "hello world"},
+		{"empty", "   ", ""},
+		{"truncate", strings.Repeat("a", 200), strings.Repeat("a", maxAnalyticsQueryLength)},
+		{"truncate multibyte", strings.Repeat("a", 127) + "é", strings.Repeat("a", 127)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeQuery(tt.query); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		hi      int
+		want    int
+		wantErr bool
+	}{
+		{"default", "/", 168, 24, false},
+		{"valid", "/?hours=48", 168, 48, false},
+		{"default capped", "/", 12, 12, false},
+		{"too large", "/?hours=200", 168, 0, true},
+		{"zero", "/?hours=0", 168, 0, true},
+		{"not a number", "/?hours=abc", 168, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+
+			got, err := intParam(r, "hours", 24, 1, tt.hi)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
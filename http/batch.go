@@ -0,0 +1,162 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/store"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	maxBatchSize     = 50
+	maxBatchBodySize = 1024 * 1024
+	maxBatchLimit    = 100
+
+	// number of queries sent to Redis in a single pipeline
+	batchChunkSize = 10
+	// number of pipelines in flight
+	batchWorkers = 4
+)
+
+type batchQuery struct {
+	Query  string   `json:"query"`
+	Limit  int      `json:"limit"`
+	Sort   string   `json:"sort"`
+	Fields []string `json:"fields"`
+}
+
+type batchResult struct {
+	Count   int64           `json:"count"`
+	Results []*store.Result `json:"results"`
+	Error   string          `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"`
+}
+
+func (b *batchResult) setError(err error) {
+	e := toAPIError(err)
+	b.Error, b.Code = e.Message, e.Code
+}
+
+func (s *Server) batchSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	start := time.Now()
+
+	var queries []batchQuery
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize))
+	if err := dec.Decode(&queries); err != nil {
+		errorResponse(w, errBadRequest("invalid request body: %v", err))
+		return
+	}
+	// every query costs a token, so a batch cannot exceed the burst
+	maxSize := maxBatchSize
+	if s.limiter != nil {
+		maxSize = min(maxSize, s.limiter.burst)
+	}
+	if len(queries) == 0 || len(queries) > maxSize {
+		errorResponse(w, errBadRequest("batch must contain between 1 and %d queries", maxSize))
+		return
+	}
+	// the rate limit middleware has already charged the first query
+	if s.limiter != nil && len(queries) > 1 {
+		ip := clientIP(r, s.limiter.trusted)
+		if ok, retryAfter := s.limiter.allowN(ip, len(queries)-1); !ok {
+			tooManyRequests(w, retryAfter)
+			return
+		}
+	}
+
+	results := make([]batchResult, len(queries))
+
+	// only valid queries are sent to Redis
+	var (
+		valid []int
+		qs    []string
+		opts  []*store.SearchOptions
+	)
+	for i, q := range queries {
+		query, opt, err := parseBatchQuery(q)
+		if err != nil {
+			results[i].setError(err)
+			continue
+		}
+		valid = append(valid, i)
+		qs = append(qs, query)
+		opts = append(opts, opt)
+	}
+
+	var g errgroup.Group
+	g.SetLimit(batchWorkers)
+
+	for lo := 0; lo < len(valid); lo += batchChunkSize {
+		hi := lo + batchChunkSize
+		if hi > len(valid) {
+			hi = len(valid)
+		}
+		lo := lo
+
+		// each chunk writes to distinct indices of results
+		g.Go(func() error {
+			res, err := s.store.SearchBatch(r.Context(), qs[lo:hi], opts[lo:hi])
+			for j, idx := range valid[lo:hi] {
+				if err != nil {
+					results[idx].setError(err)
+					continue
+				}
+				if res[j].Err != nil {
+					results[idx].setError(res[j].Err)
+					continue
+				}
+				results[idx].Count = res[j].Count
+				results[idx].Results = res[j].Results
+				metrics.ObserveSearch(res[j].Count)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	timeTaken := time.Since(start)
+	slog.InfoContext(r.Context(), "batch search", "queries", len(queries), "duration", timeTaken)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results":    results,
+		"query_time": timeTaken.Seconds(),
+	})
+}
+
+func parseBatchQuery(q batchQuery) (string, *store.SearchOptions, error) {
+	if q.Query == "" {
+		return "", nil, errInvalidQuery(nil, "query required")
+	}
+	if q.Limit < 0 || q.Limit > maxBatchLimit {
+		return "", nil, errBadRequest("limit must be between 0 and %d", maxBatchLimit)
+	}
+
+	query, err := parseQuery(q.Query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sortBy, asc, err := parseSort(q.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fields, err := parseFields(q.Fields)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return query, &store.SearchOptions{
+		Limit:     q.Limit,
+		SortBy:    sortBy,
+		Ascending: asc,
+		Fields:    fields,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/kencx/sxkcd/store"
+)
+
+// Machine readable error codes returned in the "code" field of every error
+// response
+const (
+	codeBadRequest   = "bad_request"
+	codeUnauthorized = "unauthorized"
+	codeConflict     = "conflict"
+	codeInvalidQuery = "invalid_query"
+	codeNotFound     = "not_found"
+	codeUnavailable  = "backend_unavailable"
+	codeTimeout      = "timeout"
+	codeRateLimited  = "rate_limited"
+	codeInternal     = "internal_error"
+)
+
+// apiError is an error with an HTTP status, a stable error code and optional
+// details, such as the position of a query parse failure
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]interface{}
+	err     error
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// Extensions exposes the error code to GraphQL clients
+func (e *apiError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	for k, v := range e.Details {
+		ext[k] = v
+	}
+	return ext
+}
+
+func errBadRequest(format string, a ...interface{}) *apiError {
+	return &apiError{
+		Status:  http.StatusBadRequest,
+		Code:    codeBadRequest,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func errInvalidQuery(details map[string]interface{}, format string, a ...interface{}) *apiError {
+	return &apiError{
+		Status:  http.StatusBadRequest,
+		Code:    codeInvalidQuery,
+		Message: fmt.Sprintf(format, a...),
+		Details: details,
+	}
+}
+
+// toAPIError maps err to an apiError. Errors that are not recognized are
+// treated as internal errors.
+func toAPIError(err error) *apiError {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return ae
+	}
+
+	e := &apiError{Message: err.Error(), err: err}
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		e.Status, e.Code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, store.ErrInvalidQuery):
+		e.Status, e.Code = http.StatusBadRequest, codeInvalidQuery
+	case errors.Is(err, store.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		e.Status, e.Code = http.StatusGatewayTimeout, codeTimeout
+	case errors.Is(err, store.ErrUnavailable):
+		e.Status, e.Code = http.StatusServiceUnavailable, codeUnavailable
+	default:
+		e.Status, e.Code = http.StatusInternalServerError, codeInternal
+	}
+	return e
+}
+
+func errorResponse(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+
+	body := map[string]interface{}{
+		"error": e.Message,
+		"code":  e.Code,
+	}
+	if len(e.Details) > 0 {
+		body["details"] = e.Details
+	}
+	writeJSON(w, e.Status, body)
+}
+
+// writeJSON encodes v before writing any headers so that encoding failures
+// can still be reported with the correct status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		slog.Error("failed to encode response", "err", err)
+
+		buf.Reset()
+		status = http.StatusInternalServerError
+		fmt.Fprintf(&buf, "{\"error\":%q,\"code\":%q}\n", "failed to encode response", codeInternal)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
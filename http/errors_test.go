@@ -0,0 +1,56 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kencx/sxkcd/store"
+)
+
+func TestToAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("failed: %w", store.ErrNotFound), http.StatusNotFound, codeNotFound},
+		{"invalid query", fmt.Errorf("failed: %w", store.ErrInvalidQuery), http.StatusBadRequest, codeInvalidQuery},
+		{"unavailable", fmt.Errorf("failed: %w", store.ErrUnavailable), http.StatusServiceUnavailable, codeUnavailable},
+		{"timeout", fmt.Errorf("failed: %w", store.ErrTimeout), http.StatusGatewayTimeout, codeTimeout},
+		{"api error", fmt.Errorf("failed: %w", errBadRequest("foo")), http.StatusBadRequest, codeBadRequest},
+		{"unknown", errors.New("foo"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toAPIError(tt.err)
+			if got.Status != tt.status || got.Code != tt.code {
+				t.Errorf("got (%v, %v), want (%v, %v)", got.Status, got.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	_, err := parseDateFilter("@date: 2035-13-35")
+	if err == nil {
+		t.Fatalf("expected err: unable to parse datetime string")
+	}
+
+	rec := httptest.NewRecorder()
+	errorResponse(rec, err)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+
+	want := `"details":{"position":7,"value":"2035-13-35"}`
+	if got := rec.Body.String(); !strings.Contains(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/store"
+)
+
+const (
+	schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# Comic with the given number, or null if it does not exist
+	comic(num: Int!): Comic
+	# Most recent comic in the index
+	latest: Comic
+	# Full-text search with the same query syntax as /search
+	search(query: String!, first: Int = 20, after: String, sort: Sort = RELEVANCE): SearchConnection!
+}
+
+enum Sort {
+	RELEVANCE
+	NEWEST
+	OLDEST
+}
+
+type Comic {
+	num: Int!
+	title: String!
+	alt: String!
+	transcript: String!
+	explanation: String!
+	# ISO-8601 publication date
+	date: String!
+	image: String!
+}
+
+type SearchConnection {
+	count: Int!
+	edges: [SearchEdge!]!
+	pageInfo: PageInfo!
+}
+
+type SearchEdge {
+	cursor: String!
+	node: Comic!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+`
+
+	maxPageSize   = 100
+	cursorPrefix  = "cursor:"
+	graphqlSortBy = "num"
+)
+
+func (s *Server) graphqlHandler() (http.Handler, error) {
+	sc, err := graphql.ParseSchema(schema, &resolver{s.store})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse graphql schema: %w", err)
+	}
+	return &relay.Handler{Schema: sc}, nil
+}
+
+type resolver struct {
+	store store.Store
+}
+
+func (r *resolver) Comic(ctx context.Context, args struct{ Num int32 }) (*comicResolver, error) {
+	c, err := r.store.Get(ctx, int(args.Num))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, toAPIError(err)
+	}
+	return &comicResolver{c}, nil
+}
+
+func (r *resolver) Latest(ctx context.Context) (*comicResolver, error) {
+	c, err := r.store.Latest(ctx)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, toAPIError(err)
+	}
+	return &comicResolver{c}, nil
+}
+
+type searchArgs struct {
+	Query string
+	First int32
+	After *string
+	Sort  string
+}
+
+func (r *resolver) Search(ctx context.Context, args searchArgs) (*connectionResolver, error) {
+	if args.First < 0 || args.First > maxPageSize {
+		return nil, errBadRequest("first must be between 0 and %d", maxPageSize)
+	}
+
+	query, err := parseQuery(args.Query)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errInvalidQuery(nil, "query required")
+	}
+
+	offset := 0
+	if args.After != nil {
+		offset, err = decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		offset++
+	}
+
+	opts := &store.SearchOptions{
+		Offset: offset,
+		Limit:  int(args.First),
+		// only the count is fetched if no edges are requested
+		CountOnly: args.First == 0,
+	}
+	switch args.Sort {
+	case "NEWEST":
+		opts.SortBy = graphqlSortBy
+	case "OLDEST":
+		opts.SortBy = graphqlSortBy
+		opts.Ascending = true
+	}
+
+	count, comics, err := r.store.SearchComics(ctx, query, opts)
+	if err != nil {
+		return nil, toAPIError(err)
+	}
+	metrics.ObserveSearch(count)
+
+	edges := make([]*edgeResolver, len(comics))
+	for i, c := range comics {
+		edges[i] = &edgeResolver{
+			cursor: encodeCursor(offset + i),
+			node:   &comicResolver{c},
+		}
+	}
+
+	return &connectionResolver{
+		count:   count,
+		edges:   edges,
+		hasNext: int64(offset+len(comics)) < count,
+	}, nil
+}
+
+type comicResolver struct {
+	c *data.Comic
+}
+
+func (r *comicResolver) Num() int32          { return int32(r.c.Number) }
+func (r *comicResolver) Title() string       { return r.c.Title }
+func (r *comicResolver) Alt() string         { return r.c.Alt }
+func (r *comicResolver) Transcript() string  { return r.c.Transcript }
+func (r *comicResolver) Explanation() string { return r.c.Explanation }
+func (r *comicResolver) Image() string       { return r.c.ImgUrl }
+
+func (r *comicResolver) Date() string {
+	return time.Unix(r.c.Date, 0).UTC().Format("2006-01-02")
+}
+
+type connectionResolver struct {
+	count   int64
+	edges   []*edgeResolver
+	hasNext bool
+}
+
+func (r *connectionResolver) Count() int32                { return int32(r.count) }
+func (r *connectionResolver) Edges() []*edgeResolver      { return r.edges }
+func (r *connectionResolver) PageInfo() *pageInfoResolver { return &pageInfoResolver{r} }
+
+type edgeResolver struct {
+	cursor string
+	node   *comicResolver
+}
+
+func (r *edgeResolver) Cursor() string       { return r.cursor }
+func (r *edgeResolver) Node() *comicResolver { return r.node }
+
+type pageInfoResolver struct {
+	conn *connectionResolver
+}
+
+func (r *pageInfoResolver) HasNextPage() bool { return r.conn.hasNext }
+
+func (r *pageInfoResolver) EndCursor() *string {
+	if len(r.conn.edges) == 0 {
+		return nil
+	}
+	return &r.conn.edges[len(r.conn.edges)-1].cursor
+}
+
+// cursors are opaque base64 encoded result offsets
+func encodeCursor(offset int) string {
+	return base64.URLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errBadRequest("invalid cursor %q", cursor)
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(b), cursorPrefix))
+	if err != nil || !strings.HasPrefix(string(b), cursorPrefix) || offset < 0 {
+		return 0, errBadRequest("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+func TestGraphqlSchema(t *testing.T) {
+	if _, err := graphql.ParseSchema(schema, &resolver{}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		want := 42
+
+		got, err := decodeCursor(encodeCursor(want))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		for _, c := range []string{"foo", "Zm9vOjE=", encodeCursor(-1)} {
+			if _, err := decodeCursor(c); err == nil {
+				t.Errorf("expected err for cursor %q", c)
+			}
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+
+	readyTimeout = 2 * time.Second
+	// ping latency above which the backend is reported as degraded
+	slowPing = 100 * time.Millisecond
+	// age of the newest comic above which the index is reported as stale.
+	// xkcd is published three times a week.
+	maxComicAge = 14 * 24 * time.Hour
+)
+
+// check is the result of a single readiness check. Only failed checks mark
+// the instance as not ready, warnings are informational.
+type check struct {
+	Status  string                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// livezHandler reports that the process is up
+func (s *Server) livezHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "ok",
+		"version": s.Version,
+	})
+}
+
+// readyzHandler reports whether the instance can serve searches. It returns
+// 503 if any check fails.
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	checks := s.readiness(ctx)
+
+	status, code := "ready", http.StatusOK
+	for _, c := range checks {
+		if c.Status == checkFail {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	writeJSON(w, code, map[string]interface{}{
+		"status":  status,
+		"version": s.Version,
+		"checks":  checks,
+	})
+}
+
+func (s *Server) readiness(ctx context.Context) map[string]check {
+	st := s.store
+	checks := make(map[string]check)
+
+	latency, err := st.Ping(ctx)
+	if err != nil {
+		checks["backend"] = check{Status: checkFail, Message: err.Error()}
+
+		// all other checks depend on the backend
+		return checks
+	}
+	checks["backend"] = pingCheck(latency)
+
+	ok, err := st.CheckIndex(ctx)
+	switch {
+	case err != nil:
+		checks["index"] = check{Status: checkFail, Message: err.Error()}
+	case !ok:
+		checks["index"] = check{Status: checkFail, Message: "index does not exist"}
+	default:
+		checks["index"] = check{Status: checkOK}
+	}
+
+	count, err := st.Count(ctx)
+	switch {
+	case err != nil:
+		checks["documents"] = check{Status: checkFail, Message: err.Error()}
+	case count <= 0:
+		checks["documents"] = check{Status: checkFail, Message: "no comics indexed"}
+	default:
+		checks["documents"] = check{
+			Status:  checkOK,
+			Details: map[string]interface{}{"count": count},
+		}
+	}
+
+	latest, err := st.Latest(ctx)
+	if err != nil {
+		checks["newest_comic"] = check{Status: checkFail, Message: err.Error()}
+	} else {
+		checks["newest_comic"] = comicAgeCheck(latest.Number, time.Unix(latest.Date, 0), timeNow())
+	}
+
+	checks["worker"] = workerCheck(s.worker.Started(), s.worker.LastSuccess(), s.worker.Interval(), timeNow())
+	return checks
+}
+
+func pingCheck(latency time.Duration) check {
+	c := check{
+		Status:  checkOK,
+		Details: map[string]interface{}{"latency_ms": float64(latency.Microseconds()) / 1000},
+	}
+	if latency > slowPing {
+		c.Status = checkWarn
+		c.Message = fmt.Sprintf("ping latency above %v", slowPing)
+	}
+	return c
+}
+
+func comicAgeCheck(num int, date, now time.Time) check {
+	age := now.Sub(date)
+	c := check{
+		Status: checkOK,
+		Details: map[string]interface{}{
+			"num":      num,
+			"date":     date.UTC().Format("2006-01-02"),
+			"age_days": int(age.Hours() / 24),
+		},
+	}
+	if age > maxComicAge {
+		c.Status = checkWarn
+		c.Message = fmt.Sprintf("newest comic is older than %d days", int(maxComicAge.Hours()/24))
+	}
+	return c
+}
+
+// workerCheck warns if the worker has not succeeded within two intervals of
+// its last success, or of its start if it has never succeeded
+func workerCheck(started, lastSuccess time.Time, interval time.Duration, now time.Time) check {
+	if started.IsZero() {
+		return check{Status: checkWarn, Message: "worker not started"}
+	}
+
+	c := check{Status: checkOK, Details: map[string]interface{}{}}
+
+	since := started
+	if !lastSuccess.IsZero() {
+		since = lastSuccess
+		c.Details["last_success"] = lastSuccess.UTC().Format(time.RFC3339)
+	}
+
+	if now.Sub(since) > 2*interval {
+		c.Status = checkWarn
+		c.Message = fmt.Sprintf("no successful run since %s", since.UTC().Format(time.RFC3339))
+	}
+	return c
+}
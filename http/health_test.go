@@ -0,0 +1,47 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkerCheck(t *testing.T) {
+	now := time.Date(2022, 1, 10, 0, 0, 0, 0, time.UTC)
+	interval := 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		started     time.Time
+		lastSuccess time.Time
+		want        string
+	}{
+		{"not started", time.Time{}, time.Time{}, checkWarn},
+		{"recently started", now.Add(-time.Hour), time.Time{}, checkOK},
+		{"never succeeded", now.Add(-72 * time.Hour), time.Time{}, checkWarn},
+		{"recent success", now.Add(-72 * time.Hour), now.Add(-24 * time.Hour), checkOK},
+		{"stale success", now.Add(-96 * time.Hour), now.Add(-72 * time.Hour), checkWarn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := workerCheck(tt.started, tt.lastSuccess, interval, now)
+			if got.Status != tt.want {
+				t.Errorf("got %v, want %v", got.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestComicAgeCheck(t *testing.T) {
+	now := time.Date(2022, 1, 30, 0, 0, 0, 0, time.UTC)
+
+	got := comicAgeCheck(2500, now.Add(-48*time.Hour), now)
+	if got.Status != checkOK || got.Details["age_days"] != 2 {
+		t.Errorf("got %+v", got)
+	}
+
+	got = comicAgeCheck(2500, now.Add(-30*24*time.Hour), now)
+	if got.Status != checkWarn {
+		t.Errorf("got %v, want %v", got.Status, checkWarn)
+	}
+}
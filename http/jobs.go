@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type jobStatus string
+
+const (
+	jobRunning   jobStatus = "running"
+	jobSucceeded jobStatus = "succeeded"
+	jobFailed    jobStatus = "failed"
+
+	// number of finished jobs kept for status queries
+	maxFinishedJobs = 50
+)
+
+// job is a long running admin task
+type job struct {
+	ID         int        `json:"id"`
+	Type       string     `json:"type"`
+	Status     jobStatus  `json:"status"`
+	Progress   string     `json:"progress,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type jobs struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   map[int]*job
+}
+
+func newJobs() *jobs {
+	return &jobs{
+		nextID: 1,
+		jobs:   make(map[int]*job),
+	}
+}
+
+// start runs f in the background as a new job of type typ. Only a single job
+// of each type may run at a time. The job outlives ctx, such as the context of
+// the request that started it, but keeps its values.
+func (js *jobs) start(ctx context.Context, typ string, f func(ctx context.Context, progress func(string, ...interface{})) error) (job, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	for _, j := range js.jobs {
+		if j.Type == typ && j.Status == jobRunning {
+			return job{}, &apiError{
+				Status:  http.StatusConflict,
+				Code:    codeConflict,
+				Message: fmt.Sprintf("%s job %d is already running", typ, j.ID),
+			}
+		}
+	}
+
+	j := &job{
+		ID:        js.nextID,
+		Type:      typ,
+		Status:    jobRunning,
+		StartedAt: timeNow(),
+	}
+	js.jobs[j.ID] = j
+	js.nextID++
+	js.prune()
+
+	progress := func(format string, a ...interface{}) {
+		msg := fmt.Sprintf(format, a...)
+		slog.Info(msg, "job_id", j.ID, "job_type", typ)
+
+		js.mu.Lock()
+		defer js.mu.Unlock()
+		j.Progress = msg
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		err := f(ctx, progress)
+
+		js.mu.Lock()
+		defer js.mu.Unlock()
+
+		now := timeNow()
+		j.FinishedAt = &now
+		if err != nil {
+			slog.Error("job failed", "job_id", j.ID, "job_type", typ, "err", err)
+			j.Status = jobFailed
+			j.Error = err.Error()
+		} else {
+			j.Status = jobSucceeded
+		}
+	}()
+	return *j, nil
+}
+
+func (js *jobs) get(id int) (job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	j, ok := js.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// list returns all jobs, most recent first
+func (js *jobs) list() []job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	result := make([]job, 0, len(js.jobs))
+	for _, j := range js.jobs {
+		result = append(result, *j)
+	}
+	sort.Slice(result, func(i, k int) bool {
+		return result[i].ID > result[k].ID
+	})
+	return result
+}
+
+// prune removes the oldest finished jobs. Callers must hold mu.
+func (js *jobs) prune() {
+	var finished []int
+	for id, j := range js.jobs {
+		if j.Status != jobRunning {
+			finished = append(finished, id)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Ints(finished)
+	for _, id := range finished[:len(finished)-maxFinishedJobs] {
+		delete(js.jobs, id)
+	}
+}
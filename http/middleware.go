@@ -0,0 +1,272 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/util"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// clients that have not made a request within this duration are evicted
+	clientTTL = 3 * time.Minute
+
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 64
+)
+
+// requestID assigns every request an ID, which is included in all logs of the
+// request and returned in the X-Request-ID header. Valid IDs sent by the
+// client are reused.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(util.WithRequestID(r.Context(), id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(timeNow().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// anonymizeIP masks the host part of ip, keeping the /24 of IPv4 and the /48
+// of IPv6 addresses
+func anonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// instrument records the request count and latency of h under route. route
+// should be a fixed pattern to keep the number of label values bounded.
+func instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h.ServeHTTP(rec, r)
+
+		method := r.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			method = "OTHER"
+		}
+
+		status := strconv.Itoa(rec.status)
+		metrics.RequestsTotal.WithLabelValues(route, method, status).Inc()
+		metrics.RequestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter is a token bucket rate limiter per client IP
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        rate.Limit
+	burst       int
+	clients     map[string]*client
+	lastCleanup time.Time
+	trusted     []*net.IPNet
+}
+
+func newRateLimiter(rps float64, burst int, trusted []*net.IPNet) *rateLimiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rps))
+	}
+
+	return &rateLimiter{
+		rate:        rate.Limit(rps),
+		burst:       burst,
+		clients:     make(map[string]*client),
+		lastCleanup: timeNow(),
+		trusted:     trusted,
+	}
+}
+
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r, rl.trusted)
+
+		if ok, retryAfter := rl.allow(ip); !ok {
+			tooManyRequests(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tooManyRequests writes a rate limited error that asks the client to retry
+// after retryAfter
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	errorResponse(w, &apiError{
+		Status:  http.StatusTooManyRequests,
+		Code:    codeRateLimited,
+		Message: "too many requests",
+		Details: map[string]interface{}{
+			"retry_after": secs,
+		},
+	})
+}
+
+// allow consumes a token from the bucket of ip. If no token is available, it
+// returns the duration until the next token.
+func (rl *rateLimiter) allow(ip string) (bool, time.Duration) {
+	return rl.allowN(ip, 1)
+}
+
+// allowN consumes n tokens from the bucket of ip. If fewer than n tokens are
+// available, none are consumed and it returns the duration until there are
+// enough.
+func (rl *rateLimiter) allowN(ip string, n int) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := timeNow()
+	if now.Sub(rl.lastCleanup) > clientTTL {
+		for k, c := range rl.clients {
+			if now.Sub(c.lastSeen) > clientTTL {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastCleanup = now
+	}
+
+	c, ok := rl.clients[ip]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.clients[ip] = c
+	}
+	c.lastSeen = now
+
+	res := c.limiter.ReserveN(now, n)
+	if !res.OK() {
+		return false, time.Second
+	}
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// clientIP returns the IP of the client. X-Forwarded-For is only honored
+// when the request is sent by a trusted proxy, in which case the right-most
+// untrusted address is the client.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrusted(host, trusted) {
+		return host
+	}
+
+	xff := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(xff) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(xff[i])
+		if ip == "" {
+			continue
+		}
+		if !isTrusted(ip, trusted) {
+			return ip
+		}
+		host = ip
+	}
+	return host
+}
+
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses a list of CIDRs or single IP addresses
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"no proxy", "1.2.3.4:5000", "", "1.2.3.4"},
+		{"untrusted proxy", "1.2.3.4:5000", "5.6.7.8", "1.2.3.4"},
+		{"trusted proxy", "10.0.0.1:5000", "5.6.7.8", "5.6.7.8"},
+		{"spoofed header", "10.0.0.1:5000", "9.9.9.9, 5.6.7.8", "5.6.7.8"},
+		{"chained proxies", "192.168.1.1:5000", "5.6.7.8, 10.0.0.2", "5.6.7.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/search", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+
+			if got := clientIP(r, trusted); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	rl := newRateLimiter(1, 2, nil)
+	h := rl.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/search", nil)
+		r.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := do("1.2.3.4:5000"); rec.Code != http.StatusOK {
+			t.Fatalf("got %v, want %v", rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := do("1.2.3.4:5000")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("got Retry-After %v, want %v", got, "1")
+	}
+
+	// other clients are not affected
+	if rec := do("5.6.7.8:5000"); rec.Code != http.StatusOK {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	now = now.Add(time.Second)
+	if rec := do("1.2.3.4:5000"); rec.Code != http.StatusOK {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusOK)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	h := instrument("/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	counter := metrics.RequestsTotal.WithLabelValues("/test", "OTHER", "418")
+	before := testutil.ToFloat64(counter)
+
+	r := httptest.NewRequest("FOO", "/test", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("got %v, want %v", got, 1)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = util.RequestID(r.Context())
+	}))
+
+	t.Run("generated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search", nil))
+
+		if got == "" || rec.Header().Get(requestIDHeader) != got {
+			t.Errorf("got %q, want %q", got, rec.Header().Get(requestIDHeader))
+		}
+	})
+
+	t.Run("client provided", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/search", nil)
+		r.Header.Set(requestIDHeader, "foo-123")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		if got != "foo-123" || rec.Header().Get(requestIDHeader) != "foo-123" {
+			t.Errorf("got %q, want %q", got, "foo-123")
+		}
+	})
+
+	t.Run("invalid client id", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/search", nil)
+		r.Header.Set(requestIDHeader, "foo bar\n")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got == "foo bar\n" {
+			t.Errorf("expected new request id")
+		}
+	})
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3.4":         "1.2.3.0",
+		"2001:db8:1:2::1": "2001:db8:1::",
+		"invalid":         "",
+	}
+	for ip, want := range tests {
+		if got := anonymizeIP(ip); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
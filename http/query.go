@@ -5,10 +5,81 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/kencx/sxkcd/store"
 )
 
 var timeNow = time.Now
 
+// query cost limits
+const (
+	maxQueryLength    = 256
+	maxQueryTerms     = 16
+	maxQueryWildcards = 3
+	minPrefixLength   = 2
+)
+
+// fields that may be selected in search results. num is always returned.
+var selectableFields = map[string]bool{
+	"title":       true,
+	"alt":         true,
+	"transcript":  true,
+	"explanation": true,
+	"img_url":     true,
+	"date":        true,
+}
+
+// parseQuery sanitizes a user query and translates the custom number and date
+// filters into RediSearch syntax
+func parseQuery(query string) (string, error) {
+	if err := checkCost(query); err != nil {
+		return "", err
+	}
+
+	query = sanitize(query)
+	query = parseNumFilter(query)
+	return parseDateFilter(query)
+}
+
+// checkCost rejects queries that are expensive to run, such as queries with
+// many terms or many short wildcards
+func checkCost(query string) error {
+	if len(query) > maxQueryLength {
+		return costError("query is too long", maxQueryLength, len(query))
+	}
+
+	terms := strings.FieldsFunc(query, func(r rune) bool {
+		return r == ' ' || r == '|' || r == '\t' || r == '\n'
+	})
+	if len(terms) > maxQueryTerms {
+		return costError("query has too many terms", maxQueryTerms, len(terms))
+	}
+
+	wildcards := 0
+	for _, t := range terms {
+		if !strings.Contains(t, "*") {
+			continue
+		}
+		wildcards++
+
+		prefix := strings.TrimLeft(strings.SplitN(t, "*", 2)[0], "-")
+		if len([]rune(prefix)) < minPrefixLength {
+			return costError("wildcard prefix is too short", minPrefixLength, len([]rune(prefix)))
+		}
+	}
+	if wildcards > maxQueryWildcards {
+		return costError("query has too many wildcards", maxQueryWildcards, wildcards)
+	}
+	return nil
+}
+
+func costError(msg string, limit, actual int) error {
+	return errInvalidQuery(map[string]interface{}{
+		"limit":  limit,
+		"actual": actual,
+	}, "%s", msg)
+}
+
 func sanitize(query string) string {
 	chars := []string{"{", "}", "[", "]", "(", ")", "~", ";", `"`, `'`, "%"}
 	for _, c := range chars {
@@ -34,16 +105,18 @@ func parseDateFilter(query string) (string, error) {
 	rx := regexp.MustCompile(`@date:\s?([0-9]{4}\-[0-9]{2}\-[0-9]{2})\s?[,-]?\s?([0-9]{4}\-[0-9]{2}\-[0-9]{2})?`)
 	if rx.MatchString(query) {
 		matches := rx.FindStringSubmatch(query)
+		loc := rx.FindStringSubmatchIndex(query)
+
 		from, err := epoch(matches[1])
 		if err != nil {
-			return "", err
+			return "", dateError(err, matches[1], loc[2])
 		}
 		var to int64
 
 		if matches[2] != "" {
 			to, err = epoch(matches[2])
 			if err != nil {
-				return "", err
+				return "", dateError(err, matches[2], loc[4])
 			}
 		} else {
 			to = timeNow().Unix()
@@ -53,6 +126,13 @@ func parseDateFilter(query string) (string, error) {
 	return query, nil
 }
 
+func dateError(err error, value string, pos int) error {
+	return errInvalidQuery(map[string]interface{}{
+		"value":    value,
+		"position": pos,
+	}, "%v", err)
+}
+
 // convert datetime string to epoch time
 func epoch(s string) (int64, error) {
 	date, err := time.Parse("2006-01-02", s)
@@ -61,3 +141,47 @@ func epoch(s string) (int64, error) {
 	}
 	return date.Unix(), nil
 }
+
+// parseSort translates a sort order into a sortable field and direction. An
+// empty string or "relevance" sorts by relevance.
+func parseSort(sort string) (string, bool, error) {
+	switch strings.ToLower(sort) {
+	case "", "relevance":
+		return "", false, nil
+	case "newest":
+		return "num", false, nil
+	case "oldest":
+		return "num", true, nil
+	default:
+		return "", false, errBadRequest("invalid sort %q", sort)
+	}
+}
+
+// parseMode validates a search mode. An empty string is keyword search.
+func parseMode(mode string) (string, error) {
+	switch m := strings.ToLower(mode); m {
+	case "", store.ModeKeyword, store.ModeSemantic, store.ModeHybrid:
+		return m, nil
+	default:
+		return "", errBadRequest("invalid mode %q", mode)
+	}
+}
+
+// parseFields validates the selected result fields and removes duplicates
+func parseFields(fields []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" || f == "num" || seen[f] {
+			continue
+		}
+		if !selectableFields[f] {
+			return nil, errBadRequest("invalid field %q", f)
+		}
+		seen[f] = true
+		result = append(result, f)
+	}
+	return result, nil
+}
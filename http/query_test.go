@@ -1,6 +1,9 @@
 package http
 
 import (
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -146,3 +149,108 @@ func TestHandleDateSearch(t *testing.T) {
 		}
 	})
 }
+
+func TestParseFields(t *testing.T) {
+	t.Run("valid fields", func(t *testing.T) {
+		want := []string{"title", "explanation"}
+
+		got, err := parseFields([]string{"title", " explanation", "num", "title", ""})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		_, err := parseFields([]string{"title", "foo"})
+		if err == nil {
+			t.Errorf("expected err: invalid field")
+		}
+	})
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort   string
+		sortBy string
+		asc    bool
+	}{
+		{"", "", false},
+		{"relevance", "", false},
+		{"newest", "num", false},
+		{"Oldest", "num", true},
+	}
+
+	for _, tt := range tests {
+		sortBy, asc, err := parseSort(tt.sort)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if sortBy != tt.sortBy || asc != tt.asc {
+			t.Errorf("got (%v, %v), want (%v, %v)", sortBy, asc, tt.sortBy, tt.asc)
+		}
+	}
+
+	_, _, err := parseSort("foo")
+	if err == nil {
+		t.Fatalf("expected err: invalid sort")
+	}
+	if got := toAPIError(err); got.Status != http.StatusBadRequest || got.Code != codeBadRequest {
+		t.Errorf("got %v %v, want %v %v", got.Status, got.Code, http.StatusBadRequest, codeBadRequest)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", ""},
+		{"keyword", "keyword"},
+		{"Semantic", "semantic"},
+		{"hybrid", "hybrid"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMode(tt.mode)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+
+	if _, err := parseMode("vector"); err == nil {
+		t.Errorf("expected err: invalid mode")
+	}
+}
+
+func TestCheckCost(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		ok    bool
+	}{
+		{"simple", "foo bar|baz", true},
+		{"prefix", "foo* -bar*", true},
+		{"too many wildcards", "ab*|cd*|ef*|gh*", false},
+		{"short prefix", "a*", false},
+		{"too many terms", strings.Repeat("foo ", maxQueryTerms+1), false},
+		{"too long", strings.Repeat("a", maxQueryLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCost(tt.query)
+			if tt.ok && err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("expected err for query %q", tt.query)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/rpc"
+	"github.com/kencx/sxkcd/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const streamPageSize = 100
+
+// rpcServer implements the gRPC service with the same query pipeline as the
+// HTTP handlers
+type rpcServer struct {
+	rpc.UnimplementedSxkcdServer
+	s *Server
+}
+
+func (s *Server) newGrpcServer() *grpc.Server {
+	var opts []grpc.ServerOption
+	if s.limiter != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(s.limiter.unaryInterceptor),
+			grpc.ChainStreamInterceptor(s.limiter.streamInterceptor),
+		)
+	}
+
+	g := grpc.NewServer(opts...)
+	rpc.RegisterSxkcdServer(g, &rpcServer{s: s})
+	return g
+}
+
+func (r *rpcServer) Search(ctx context.Context, req *rpc.SearchRequest) (*rpc.SearchResponse, error) {
+	start := time.Now()
+
+	if req.Query == "" {
+		return nil, status.Error(codes.InvalidArgument, "query required")
+	}
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must be >= 0")
+	}
+	// the same page size as GraphQL
+	limit := min(int(req.Limit), maxPageSize)
+
+	query, err := parseQuery(req.Query)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	opts := &store.SearchOptions{
+		Offset: int(req.Offset),
+		Limit:  limit,
+	}
+	switch req.Sort {
+	case rpc.Sort_SORT_NEWEST:
+		opts.SortBy = "num"
+	case rpc.Sort_SORT_OLDEST:
+		opts.SortBy = "num"
+		opts.Ascending = true
+	}
+
+	count, comics, err := r.s.store.SearchComics(ctx, query, opts)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	metrics.ObserveSearch(count)
+
+	results := make([]*rpc.Comic, len(comics))
+	for i, c := range comics {
+		results[i] = toProto(c)
+	}
+
+	return &rpc.SearchResponse{
+		Count:     count,
+		Results:   results,
+		QueryTime: time.Since(start).Seconds(),
+	}, nil
+}
+
+func (r *rpcServer) GetComic(ctx context.Context, req *rpc.GetComicRequest) (*rpc.Comic, error) {
+	if req.Num < 0 {
+		return nil, status.Error(codes.InvalidArgument, "comic number must be >= 0")
+	}
+
+	var (
+		c   *data.Comic
+		err error
+	)
+	if req.Num == 0 {
+		c, err = r.s.store.Latest(ctx)
+	} else {
+		c, err = r.s.store.Get(ctx, int(req.Num))
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "comic %d not found", req.Num)
+		}
+		return nil, grpcError(err)
+	}
+	return toProto(c), nil
+}
+
+func (r *rpcServer) StreamComics(req *rpc.StreamComicsRequest, stream rpc.Sxkcd_StreamComicsServer) error {
+	if req.From < 0 || req.To < 0 || (req.To > 0 && req.To < req.From) {
+		return status.Error(codes.InvalidArgument, "invalid comic range")
+	}
+
+	to := "+inf"
+	if req.To > 0 {
+		to = fmt.Sprint(req.To)
+	}
+	query := fmt.Sprintf("@num: [%d %s]", req.From, to)
+
+	for offset := 0; ; offset += streamPageSize {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+
+		count, comics, err := r.s.store.SearchComics(stream.Context(), query, &store.SearchOptions{
+			Offset:    offset,
+			Limit:     streamPageSize,
+			SortBy:    "num",
+			Ascending: true,
+		})
+		if err != nil {
+			return grpcError(err)
+		}
+
+		for _, c := range comics {
+			if err := stream.Send(toProto(c)); err != nil {
+				return err
+			}
+		}
+
+		if len(comics) == 0 || int64(offset+len(comics)) >= count {
+			return nil
+		}
+	}
+}
+
+func (r *rpcServer) WatchNewComics(req *rpc.WatchNewComicsRequest, stream rpc.Sxkcd_WatchNewComicsServer) error {
+	ch, cancel := r.s.worker.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case c, ok := <-ch:
+			// worker stopped
+			if !ok {
+				return status.Error(codes.Unavailable, "server shutting down")
+			}
+			if err := stream.Send(toProto(&c)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// unaryInterceptor rejects calls of clients that exceeded their rate limit
+func (rl *rateLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := rl.allowPeer(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor rejects streams of clients that exceeded their rate
+// limit. A stream counts as a single request.
+func (rl *rateLimiter) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := rl.allowPeer(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// allowPeer consumes a token of the client IP of a gRPC call, which is the
+// address of the peer
+func (rl *rateLimiter) allowPeer(ctx context.Context) error {
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	if ok, retryAfter := rl.allow(ip); !ok {
+		secs := int(math.Ceil(retryAfter.Seconds()))
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(secs)))
+		return status.Error(codes.ResourceExhausted, "too many requests")
+	}
+	return nil
+}
+
+// grpcError maps err to a gRPC status with the same semantics as the HTTP
+// status codes
+func grpcError(err error) error {
+	e := toAPIError(err)
+
+	var code codes.Code
+	switch e.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, e.Message)
+}
+
+func toProto(c *data.Comic) *rpc.Comic {
+	return &rpc.Comic{
+		Num:         int32(c.Number),
+		Title:       c.Title,
+		Alt:         c.Alt,
+		Transcript:  c.Transcript,
+		ImgUrl:      c.ImgUrl,
+		Explanation: c.Explanation,
+		Date:        c.Date,
+	}
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/rpc"
+	"github.com/kencx/sxkcd/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// comicStore is a fakeStore that records the options of SearchComics
+type comicStore struct {
+	fakeStore
+}
+
+func (c *comicStore) SearchComics(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	c.query, c.opts = query, opts
+	return 0, nil, nil
+}
+
+func TestRPCSearchLimit(t *testing.T) {
+	tests := []struct {
+		limit int32
+		want  int
+	}{
+		{0, 0},
+		{10, 10},
+		{100000, maxPageSize},
+	}
+
+	for _, tt := range tests {
+		st := &comicStore{}
+		r := &rpcServer{s: &Server{store: st}}
+
+		if _, err := r.Search(context.Background(), &rpc.SearchRequest{Query: "foo", Limit: tt.limit}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if st.opts.Limit != tt.want {
+			t.Errorf("got limit %d, want %d", st.opts.Limit, tt.want)
+		}
+	}
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	rl := newRateLimiter(1, 1, nil)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	call := func(ip string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000},
+		})
+		_, err := rl.unaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		return err
+	}
+
+	if err := call("1.2.3.4"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := call("1.2.3.4"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("got %v, want %v", err, codes.ResourceExhausted)
+	}
+	// other clients are not affected
+	if err := call("5.6.7.8"); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+}
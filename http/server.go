@@ -3,202 +3,411 @@ package http
 import (
 	"context"
 	"embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/kencx/sxkcd/redis"
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/store"
 	"github.com/kencx/sxkcd/worker"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
-	rds     *redis.Client
+	store   store.Store
 	worker  *worker.Worker
+	jobs    *jobs
 	Static  embed.FS
 	Version string
-}
 
-func NewServer(uri, version string, static embed.FS) (*Server, error) {
-	client, err := redis.New(uri)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create redis client: %w", err)
-	}
+	// RateLimit is the number of requests per second allowed per client IP,
+	// with bursts of up to RateBurst requests. Rate limiting is disabled if 0.
+	RateLimit float64
+	RateBurst int
+	// TrustedProxies is a list of IPs or CIDRs whose X-Forwarded-For header is
+	// trusted when determining the client IP
+	TrustedProxies []string
+	// AdminToken is the bearer token required by the /admin API. The admin API
+	// is disabled if empty.
+	AdminToken string
+	// QueryLog controls the logging of search queries: "off", "anonymized"
+	// (client IPs are masked) or "full"
+	QueryLog string
+	// AnalyticsRetention is how long search analytics are kept. Analytics are
+	// disabled if 0.
+	AnalyticsRetention time.Duration
+
+	trusted []*net.IPNet
+	// limiter is shared by the HTTP and gRPC servers, nil if rate limiting is
+	// disabled
+	limiter *rateLimiter
+}
 
+// NewServer returns a server that stores and searches comics in st
+func NewServer(st store.Store, version string, static embed.FS) *Server {
 	return &Server{
-		rds:     client,
-		worker:  worker.New(client),
+		store:   st,
+		worker:  worker.New(st),
+		jobs:    newJobs(),
 		Version: version,
 		Static:  static,
-	}, nil
+	}
 }
 
-func (s *Server) Initialize(filename string, reindex bool) error {
+func (s *Server) Initialize(ctx context.Context, filename string, reindex bool) error {
+	if err := s.checkConfig(ctx); err != nil {
+		return err
+	}
+	return s.initialize(ctx, filename, reindex, func(format string, a ...interface{}) {
+		slog.Info(fmt.Sprintf(format, a...))
+	})
+}
+
+// initialize indexes all comics in filename, reporting progress with the
+// given printf-like func
+func (s *Server) initialize(ctx context.Context, filename string, reindex bool, progress func(string, ...interface{})) error {
 	if filename == "" {
 		return fmt.Errorf("no filename provided")
 	}
 
+	progress("Reading comics from %s", filename)
 	comics, err := decodeFile(filename)
 	if err != nil {
 		return err
 	}
 
 	start := time.Now()
-	log.Printf("Indexing %d comics\n", len(comics))
+	progress("Indexing %d comics", len(comics))
 
-	err = s.rds.CreateIndex()
+	// comics are not guaranteed to be in order. This depends entirely on the order in
+	// which they are fetched.
+	err = s.store.CreateIndex(ctx)
+	switch {
+	case errors.Is(err, store.ErrIndexExists):
+		if !reindex {
+			return fmt.Errorf("%w, include --reindex to replace data", err)
+		}
+		err = s.store.Reindex(ctx, comics)
+	case err == nil:
+		err = s.store.AddBatch(ctx, comics)
+	}
 	if err != nil {
-		if err.Error() == "Index already exists" {
-			if reindex {
-				s.rds.Reindex()
-			} else {
-				return fmt.Errorf("%w, include --reindex to replace data", err)
-			}
-		} else {
+		return err
+	}
+
+	progress("Successfully indexed %d comics in %v", len(comics), time.Since(start))
+	s.setLatestComic(ctx)
+	return nil
+}
+
+// Merge adds the new comics and updates the changed comics of filename in the
+// existing index without replacing other comics, such as comics fetched by the
+// worker. The index is created if it does not exist.
+func (s *Server) Merge(ctx context.Context, filename string) error {
+	if err := s.checkConfig(ctx); err != nil {
+		return err
+	}
+	return s.merge(ctx, filename, func(format string, a ...interface{}) {
+		slog.Info(fmt.Sprintf(format, a...))
+	})
+}
+
+func (s *Server) merge(ctx context.Context, filename string, progress func(string, ...interface{})) error {
+	if filename == "" {
+		return fmt.Errorf("no filename provided")
+	}
+
+	ok, err := s.store.CheckIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.initialize(ctx, filename, false, progress)
+	}
+	if m, isMigrator := s.store.(store.SchemaMigrator); isMigrator {
+		if err := m.MigrateSchema(ctx); err != nil {
 			return err
 		}
 	}
 
-	// comics are not guaranteed to be in order. This depends entirely on the order in
-	// which they are fetched.
-	err = s.rds.AddBatch(comics)
+	progress("Reading comics from %s", filename)
+	comics, err := decodeFile(filename)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Successfully indexed %d comics in %v\n", len(comics), time.Since(start))
+	start := time.Now()
+	progress("Merging %d comics", len(comics))
+	res, err := store.Merge(ctx, s.store, comics)
+	if err != nil {
+		return fmt.Errorf("failed to merge comics: %w", err)
+	}
+
+	progress("Merged comics in %v: %d added, %d updated, %d unchanged",
+		time.Since(start), res.Added, res.Updated, res.Unchanged)
+	s.setLatestComic(ctx)
+	return nil
+}
+
+// setLatestComic updates the latest comic metric from the index
+func (s *Server) setLatestComic(ctx context.Context) {
+	c, err := s.store.Latest(ctx)
+	if err != nil {
+		slog.Warn("failed to get latest comic", "err", err)
+		return
+	}
+	metrics.LatestComic.Set(float64(c.Number))
+}
+
+// checkConfig checks the configuration of the backend if the store depends on it
+func (s *Server) checkConfig(ctx context.Context) error {
+	if c, ok := s.store.(store.ConfigChecker); ok {
+		return c.CheckConfig(ctx)
+	}
 	return nil
 }
 
-func (s *Server) Verify() error {
-	ok, err := s.rds.CheckIndex()
+func (s *Server) Verify(ctx context.Context) error {
+	if err := s.checkConfig(ctx); err != nil {
+		return err
+	}
+
+	ok, err := s.store.CheckIndex(ctx)
 	if err != nil {
 		return err
 	}
 
-	count, err := s.rds.Count()
+	if m, isMigrator := s.store.(store.SchemaMigrator); ok && isMigrator {
+		if err := m.MigrateSchema(ctx); err != nil {
+			return err
+		}
+	}
+
+	count, err := s.store.Count(ctx)
 	if err != nil {
 		return err
 	}
 	if count > 0 && ok {
-		log.Printf("Found existing index and %d comics", count)
+		slog.Info("found existing index", "count", count)
+		s.setLatestComic(ctx)
 	} else {
 		return fmt.Errorf("no index or comics found, please provide a file")
 	}
 	return nil
 }
 
-func (s *Server) Run(port int) error {
-
-	p := fmt.Sprintf(":%d", port)
+// routes returns the handler of all HTTP routes
+func (s *Server) routes() (http.Handler, error) {
 	mux := http.NewServeMux()
-	srv := &http.Server{
-		Addr:    p,
-		Handler: mux,
+
+	handle := func(route string, h http.Handler) {
+		mux.Handle(route, instrument(route, h))
+	}
+	// search routes are rate limited, so that health checks and metrics are
+	// always served and rejected requests are counted
+	handleLimited := func(route string, h http.Handler) {
+		if s.limiter != nil {
+			h = s.limiter.middleware(h)
+		}
+		handle(route, h)
 	}
 
-	mux.HandleFunc("/search", s.searchHandler)
-	mux.HandleFunc("/health", s.healthcheckHandler)
+	handleLimited("/search", http.HandlerFunc(s.searchHandler))
+	handleLimited("/search/batch", http.HandlerFunc(s.batchSearchHandler))
+	handle("/livez", http.HandlerFunc(s.livezHandler))
+	handle("/readyz", http.HandlerFunc(s.readyzHandler))
+	// deprecated, use /livez
+	handle("/health", http.HandlerFunc(s.livezHandler))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	gql, err := s.graphqlHandler()
+	if err != nil {
+		return nil, err
+	}
+	handleLimited("/graphql", gql)
+
+	if s.AdminToken != "" {
+		handle("/admin/", s.adminHandler())
+	}
 
 	// embed static files
 	dir, err := fs.Sub(s.Static, "ui/build")
+	if err != nil {
+		return nil, err
+	}
+	handle("/", http.FileServer(http.FS(dir)))
+	return mux, nil
+}
+
+// Run starts the HTTP server on port and blocks until SIGINT or SIGTERM is
+// received. If grpcPort > 0, the gRPC service is also started.
+func (s *Server) Run(port, grpcPort int) error {
+
+	trusted, err := parseCIDRs(s.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	s.trusted = trusted
+
+	if s.RateLimit > 0 {
+		s.limiter = newRateLimiter(s.RateLimit, s.RateBurst, s.trusted)
+	}
+
+	h, err := s.routes()
 	if err != nil {
 		return err
 	}
-	mux.Handle("/", http.FileServer(http.FS(dir)))
+	p := fmt.Sprintf(":%d", port)
+	srv := &http.Server{
+		Addr:    p,
+		Handler: requestID(h),
+	}
 
 	go func() {
 		err := srv.ListenAndServe()
 		if !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("failed to start server: %w", err)
+			slog.Error("failed to start server", "err", err)
+			os.Exit(1)
 		}
 	}()
-	log.Printf("Server started at %s", p)
+	slog.Info("server started", "addr", p)
 
-	err = s.worker.Start()
+	var grpcSrv *grpc.Server
+	if grpcPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+		if err != nil {
+			return fmt.Errorf("failed to listen on grpc port: %w", err)
+		}
+
+		grpcSrv = s.newGrpcServer()
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				slog.Error("failed to start grpc server", "err", err)
+				os.Exit(1)
+			}
+		}()
+		slog.Info("grpc server started", "addr", fmt.Sprintf(":%d", grpcPort))
+	}
+
+	// scheduled runs of the worker are cancelled on shutdown
+	ctx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+
+	err = s.worker.Start(ctx)
 	if err != nil {
-		log.Printf("worker failed to start: %w", err)
+		slog.Error("worker failed to start", "err", err)
 	}
 
 	// graceful shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-sigCh
-	log.Printf("Received signal %s, shutting down...", sig.String())
+	slog.Info("received signal, shutting down", "signal", sig.String())
 
 	tc, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	cancelWorker()
 	s.worker.Stop()
 	if err := srv.Shutdown(tc); err != nil {
-		log.Fatalf("failed to shut down gracefully: %w", err)
+		slog.Error("failed to shut down gracefully", "err", err)
+		os.Exit(1)
+	}
+
+	if grpcSrv != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-tc.Done():
+			grpcSrv.Stop()
+		}
 	}
 
-	log.Printf("Application gracefully stopped")
+	slog.Info("application gracefully stopped")
 	return nil
 }
 
 func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	query := r.URL.Query().Get("q")
 	start := time.Now()
 
 	if query == "" {
-		log.Printf("invalid request parameters: %v", r.URL.Query())
-		errorResponse(w, fmt.Errorf("query parameters required"))
+		slog.DebugContext(ctx, "invalid request parameters", "params", r.URL.Query())
+		errorResponse(w, errInvalidQuery(nil, "query parameters required"))
 		return
 	}
 
-	query = sanitize(query)
-	query = parseNumFilter(query)
-	query, err := parseDateFilter(query)
+	query, err := parseQuery(query)
 	if err != nil {
-		log.Println(err)
+		slog.DebugContext(ctx, "invalid query", "err", err)
 		errorResponse(w, err)
 		return
 	}
 
-	count, results, err := s.rds.Search(query)
+	mode, err := parseMode(r.URL.Query().Get("mode"))
 	if err != nil {
-		log.Println(err)
 		errorResponse(w, err)
 		return
 	}
-	timeTaken := time.Since(start)
-	log.Printf("Query produced %d results in %.3fms: %s", count, timeTaken.Seconds()*1000, query)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"count":      count,
-		"results":    results,
-		"query_time": timeTaken.Seconds(),
-	}); err != nil {
+	// fields=title,alt or fields=title&fields=alt
+	var selected []string
+	for _, v := range r.URL.Query()["fields"] {
+		selected = append(selected, strings.Split(v, ",")...)
+	}
+	fields, err := parseFields(selected)
+	if err != nil {
 		errorResponse(w, err)
 		return
 	}
-}
 
-func (s *Server) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"version": s.Version,
-	}); err != nil {
+	count, results, err := s.store.Search(ctx, query, &store.SearchOptions{
+		Mode:   mode,
+		Fields: fields,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "search failed", "err", err)
 		errorResponse(w, err)
 		return
 	}
-}
+	timeTaken := time.Since(start)
+	metrics.ObserveSearch(count)
+	s.logQuery(r, query, count, timeTaken)
+	s.recordQuery(r, r.URL.Query().Get("q"), count, timeTaken)
 
-func errorResponse(w http.ResponseWriter, err error) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error": err.Error(),
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":      count,
+		"results":    results,
+		"query_time": timeTaken.Seconds(),
 	})
 }
+
+// logQuery logs a search query according to QueryLog
+func (s *Server) logQuery(r *http.Request, query string, count int64, d time.Duration) {
+	attrs := []any{"query", query, "results", count, "duration", d}
+
+	switch s.QueryLog {
+	case "off":
+		return
+	case "full":
+		attrs = append(attrs, "client_ip", clientIP(r, s.trusted))
+	default:
+		attrs = append(attrs, "client_ip", anonymizeIP(clientIP(r, s.trusted)))
+	}
+	slog.InfoContext(r.Context(), "search query", attrs...)
+}
@@ -0,0 +1,271 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeStore is a store.Store that returns fixed search results. Methods that
+// are not overridden panic.
+type fakeStore struct {
+	store.Store
+	query   string
+	opts    *store.SearchOptions
+	results []*store.Result
+	err     error
+}
+
+func (f *fakeStore) Search(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*store.Result, error) {
+	f.query, f.opts = query, opts
+	if f.err != nil {
+		return 0, nil, f.err
+	}
+	return int64(len(f.results)), f.results, nil
+}
+
+func TestSearchHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		err       error
+		wantQuery string
+		want      int
+	}{
+		{"valid", "/search?q=%23256", nil, "@num: [256 256]", http.StatusOK},
+		{"missing query", "/search", nil, "", http.StatusBadRequest},
+		{"invalid date", "/search?q=@date:2020-13-01", nil, "", http.StatusBadRequest},
+		{"backend error", "/search?q=foo", fmt.Errorf("failed: %w", store.ErrUnavailable), "foo", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &fakeStore{
+				results: []*store.Result{{Number: 256, Title: "Online Communities"}},
+				err:     tt.err,
+			}
+			s := &Server{store: fs, QueryLog: "off"}
+
+			rec := httptest.NewRecorder()
+			s.searchHandler(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("got %v, want %v", rec.Code, tt.want)
+			}
+			if fs.query != tt.wantQuery {
+				t.Errorf("got query %q, want %q", fs.query, tt.wantQuery)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Count   int64           `json:"count"`
+				Results []*store.Result `json:"results"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Count != 1 || body.Results[0].Number != 256 {
+				t.Errorf("got %+v", body)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerFields(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want []string
+		code int
+	}{
+		{"default", "/search?q=foo", nil, http.StatusOK},
+		{"comma-separated", "/search?q=foo&fields=title,transcript", []string{"title", "transcript"}, http.StatusOK},
+		{"repeated", "/search?q=foo&fields=title&fields=explanation,title", []string{"title", "explanation"}, http.StatusOK},
+		{"invalid", "/search?q=foo&fields=title,embedding", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &fakeStore{}
+			s := &Server{store: fs, QueryLog: "off"}
+
+			rec := httptest.NewRecorder()
+			s.searchHandler(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if rec.Code != tt.code {
+				t.Fatalf("got %v, want %v", rec.Code, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(fs.opts.Fields, tt.want) {
+				t.Errorf("got fields %v, want %v", fs.opts.Fields, tt.want)
+			}
+		})
+	}
+}
+
+// migratingStore is a fakeStore with an index that must be migrated
+type migratingStore struct {
+	fakeStore
+	migrated bool
+	err      error
+}
+
+func (m *migratingStore) CheckIndex(context.Context) (bool, error) { return true, nil }
+func (m *migratingStore) Count(context.Context) (int, error)       { return 0, nil }
+
+func (m *migratingStore) MigrateSchema(context.Context) error {
+	m.migrated = true
+	return m.err
+}
+
+func TestVerifyMigratesSchema(t *testing.T) {
+	ms := &migratingStore{err: fmt.Errorf("index schema version 3 is newer than the supported version 2")}
+	s := &Server{store: ms}
+
+	if err := s.Verify(context.Background()); err != ms.err {
+		t.Errorf("got %v, want %v", err, ms.err)
+	}
+	if !ms.migrated {
+		t.Errorf("expected schema to be migrated")
+	}
+}
+
+type misconfiguredStore struct {
+	fakeStore
+	err error
+}
+
+func (m *misconfiguredStore) CheckIndex(context.Context) (bool, error) { return true, nil }
+func (m *misconfiguredStore) Count(context.Context) (int, error)       { return 1, nil }
+func (m *misconfiguredStore) CheckConfig(context.Context) error        { return m.err }
+
+func TestVerifyChecksConfig(t *testing.T) {
+	ms := &misconfiguredStore{err: fmt.Errorf("search timeouts return partial results")}
+	s := &Server{store: ms}
+
+	if err := s.Verify(context.Background()); err != ms.err {
+		t.Errorf("got %v, want %v", err, ms.err)
+	}
+}
+
+func TestRoutesRateLimit(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	s := &Server{
+		store:   &fakeStore{},
+		limiter: newRateLimiter(1, 1, nil),
+	}
+	h, err := s.routes()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	do := func(target string) int {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = "1.2.3.4:5000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	limited := metrics.RequestsTotal.WithLabelValues("/search", "GET", "429")
+	before := testutil.ToFloat64(limited)
+
+	if code := do("/search?q=foo"); code != http.StatusOK {
+		t.Fatalf("got %v, want %v", code, http.StatusOK)
+	}
+	if code := do("/search?q=foo"); code != http.StatusTooManyRequests {
+		t.Errorf("got %v, want %v", code, http.StatusTooManyRequests)
+	}
+	// rejected requests are instrumented
+	if got := testutil.ToFloat64(limited) - before; got != 1 {
+		t.Errorf("got %v rate limited requests, want %v", got, 1)
+	}
+
+	// other routes are not rate limited
+	for i := 0; i < 3; i++ {
+		if code := do("/metrics"); code != http.StatusOK {
+			t.Errorf("got %v, want %v", code, http.StatusOK)
+		}
+	}
+}
+
+// batchStore is a fakeStore that returns no results for every query of a batch
+type batchStore struct {
+	fakeStore
+}
+
+func (b *batchStore) SearchBatch(ctx context.Context, queries []string, opts []*store.SearchOptions) ([]store.BatchResult, error) {
+	return make([]store.BatchResult, len(queries)), nil
+}
+
+func TestBatchRateLimit(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	s := &Server{
+		store:   &batchStore{},
+		limiter: newRateLimiter(1, 5, nil),
+	}
+	h, err := s.routes()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	do := func(n int) *httptest.ResponseRecorder {
+		body := "[" + strings.Repeat(`{"query": "foo"},`, n-1) + `{"query": "foo"}]`
+		r := httptest.NewRequest(http.MethodPost, "/search/batch", strings.NewReader(body))
+		r.RemoteAddr = "1.2.3.4:5000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// batches larger than the burst can never be served
+	if rec := do(6); rec.Code != http.StatusBadRequest {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+
+	now = now.Add(time.Second)
+	if rec := do(3); rec.Code != http.StatusOK {
+		t.Fatalf("got %v, want %v", rec.Code, http.StatusOK)
+	}
+	// every query is charged, leaving 2 of 5 tokens
+	rec := do(3)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("got Retry-After %v, want %v", got, "1")
+	}
+	if rec := do(1); rec.Code != http.StatusOK {
+		t.Errorf("got %v, want %v", rec.Code, http.StatusOK)
+	}
+}
+
+func TestDecodeFileStatus(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	_, err := decodeFile(ts.URL + "/comics.json")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("got %v, want 404 error", err)
+	}
+}
@@ -1,7 +1,6 @@
 package http
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +10,8 @@ import (
 	"github.com/kencx/sxkcd/data"
 )
 
+// decodeFile reads the comics of a data file in either format from a path,
+// URL or stdin
 func decodeFile(filename string) ([]data.Comic, error) {
 	var rc io.ReadCloser
 
@@ -20,8 +21,14 @@ func decodeFile(filename string) ([]data.Comic, error) {
 			return nil, fmt.Errorf("failed to get %s: %v", filename, err)
 		}
 		defer response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return nil, fmt.Errorf("failed to get %s: %s", filename, response.Status)
+		}
 		rc = response.Body
 
+	} else if filename == data.Stdio {
+		rc = os.Stdin
+
 	} else {
 		f, err := os.Open(filename)
 		if err != nil {
@@ -31,29 +38,9 @@ func decodeFile(filename string) ([]data.Comic, error) {
 		rc = f
 	}
 
-	dec := json.NewDecoder(rc)
-
-	t, err := dec.Token()
+	comics, err := data.Decode(rc)
 	if err != nil {
-		return nil, fmt.Errorf("token err: %v", err)
-	}
-	if t.(json.Delim) != '{' {
-		return nil, fmt.Errorf("not json object")
-	}
-
-	var comics []data.Comic
-	for dec.More() {
-		_, err = dec.Token()
-		if err != nil {
-			return nil, fmt.Errorf("key err: %v", err)
-		}
-
-		var val data.Comic
-		err = dec.Decode(&val)
-		if err != nil {
-			return nil, fmt.Errorf("decode err: %v", err)
-		}
-		comics = append(comics, val)
+		return nil, fmt.Errorf("failed to read %s: %v", filename, err)
 	}
 	return comics, nil
 }
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"math"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/embed"
+	"github.com/kencx/sxkcd/store/query"
+)
+
+// indexed text fields, in the order of query.TextFields
+const (
+	fieldTitle = iota
+	fieldAlt
+	fieldTranscript
+	fieldExplanation
+	numFields
+)
+
+// identical to the weights of the RediSearch schema
+var fieldWeights = [numFields]float64{50, 10, 5, 1}
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// termFreq is the number of occurrences of a term in each field of a document
+type termFreq [numFields]int
+
+type document struct {
+	comic data.Comic
+	// number of tokens in all fields
+	length int
+	terms  map[string]*termFreq
+	// embedding of the text of the comic for semantic search
+	vec []float32
+}
+
+// index is an inverted index of comics by number. It is not safe for
+// concurrent use.
+type index struct {
+	docs        map[int]*document
+	postings    map[string]map[int]*termFreq
+	totalLength int
+	embedder    embed.Embedder
+}
+
+func newIndex() *index {
+	return &index{
+		docs:     make(map[int]*document),
+		postings: make(map[string]map[int]*termFreq),
+		embedder: embed.Default(),
+	}
+}
+
+// add indexes comic c, replacing any existing document with the same number
+func (ix *index) add(c data.Comic) {
+	ix.remove(c.Number)
+
+	d := &document{
+		comic: c,
+		terms: make(map[string]*termFreq),
+		vec:   ix.embedder.Embed(embed.ComicText(&c)),
+	}
+	for f := 0; f < numFields; f++ {
+		for _, t := range query.Tokenize(fieldText(&c, f)) {
+			tf, ok := d.terms[t]
+			if !ok {
+				tf = &termFreq{}
+				d.terms[t] = tf
+			}
+			tf[f]++
+			d.length++
+		}
+	}
+
+	for t, tf := range d.terms {
+		p, ok := ix.postings[t]
+		if !ok {
+			p = make(map[int]*termFreq)
+			ix.postings[t] = p
+		}
+		p[c.Number] = tf
+	}
+	ix.docs[c.Number] = d
+	ix.totalLength += d.length
+}
+
+func (ix *index) remove(num int) {
+	d, ok := ix.docs[num]
+	if !ok {
+		return
+	}
+
+	for t := range d.terms {
+		delete(ix.postings[t], num)
+		if len(ix.postings[t]) == 0 {
+			delete(ix.postings, t)
+		}
+	}
+	delete(ix.docs, num)
+	ix.totalLength -= d.length
+}
+
+// bm25 scores a document with the given term frequencies for a term that
+// occurs in df documents. Field weights are applied to the term frequency. If
+// field is not -1, only occurrences in that field are counted.
+func (ix *index) bm25(d *document, tf *termFreq, df int, field int) float64 {
+	var wtf float64
+	for f := 0; f < numFields; f++ {
+		if field >= 0 && f != field {
+			continue
+		}
+		wtf += fieldWeights[f] * float64(tf[f])
+	}
+	if wtf == 0 {
+		return 0
+	}
+
+	n := float64(len(ix.docs))
+	idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+
+	avg := float64(ix.totalLength) / n
+	norm := 1.0
+	if avg > 0 {
+		norm = 1 - b + b*float64(d.length)/avg
+	}
+	return idf * wtf * (k1 + 1) / (wtf + k1*norm)
+}
+
+// fieldIndex returns the index of the named text field, or -1 for all fields
+func fieldIndex(name string) int {
+	for i, f := range query.TextFields {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func fieldText(c *data.Comic, field int) string {
+	switch field {
+	case fieldTitle:
+		return c.Title
+	case fieldAlt:
+		return c.Alt
+	case fieldTranscript:
+		return c.Transcript
+	case fieldExplanation:
+		return c.Explanation
+	}
+	return ""
+}
@@ -0,0 +1,471 @@
+// Package memory implements an in-process search backend that does not
+// require Redis. Comics are kept in memory and optionally persisted to a JSON
+// file in the same format as the comics file produced by download.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/embed"
+	"github.com/kencx/sxkcd/store"
+	"github.com/kencx/sxkcd/store/query"
+)
+
+var _ store.Store = (*Store)(nil)
+
+type Store struct {
+	db *db
+}
+
+type db struct {
+	mu      sync.RWMutex
+	ix      *index
+	created bool
+	// file the comics are persisted to, if any
+	path string
+}
+
+// New returns an empty store. If path is not empty, comics are loaded from
+// path if it exists and written back after every change.
+func New(path string) (*Store, error) {
+	s := &Store{
+		db: &db{ix: newIndex(), path: path},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	comics, err := data.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range comics {
+		s.db.ix.add(c)
+	}
+	s.db.created = true
+	return s, nil
+}
+
+func (s *Store) CreateIndex(ctx context.Context) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.created {
+		return store.ErrIndexExists
+	}
+	s.db.created = true
+	return nil
+}
+
+// Reindex builds a new index of comics and then swaps it with the existing
+// index, which continues to serve searches in the meantime
+func (s *Store) Reindex(ctx context.Context, comics []data.Comic) error {
+	ix := newIndex()
+	for _, c := range comics {
+		ix.add(c)
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.ix = ix
+	s.db.created = true
+	return s.db.save()
+}
+
+func (s *Store) CheckIndex(ctx context.Context) (bool, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return s.db.created, nil
+}
+
+// Ping returns the time taken to acquire a read lock on the index
+func (s *Store) Ping(ctx context.Context) (time.Duration, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return time.Since(start), nil
+}
+
+func (s *Store) Count(ctx context.Context) (int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return len(s.db.ix.docs), nil
+}
+
+// Add adds comic num, or updates the fields that changed if it already exists
+func (s *Store) Add(ctx context.Context, num int, comic []byte) error {
+	c, err := decodeComic(num, comic)
+	if err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	d, ok := s.db.ix.docs[num]
+	if !ok {
+		s.db.ix.add(c)
+		return s.db.save()
+	}
+
+	fields := store.Changes(&d.comic, &c)
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.db.patch(num, fields)
+}
+
+func (s *Store) AddBatch(ctx context.Context, comics []data.Comic) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, c := range comics {
+		s.db.ix.add(c)
+	}
+	return s.db.save()
+}
+
+func (s *Store) Replace(ctx context.Context, num int, comic []byte) error {
+	c, err := decodeComic(num, comic)
+	if err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.ix.add(c)
+	return s.db.save()
+}
+
+// Patch sets the given string fields of comic num
+func (s *Store) Patch(ctx context.Context, num int, fields map[string]string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	return s.db.patch(num, fields)
+}
+
+func (s *Store) Delete(ctx context.Context, num int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.ix.docs[num]; !ok {
+		return store.ErrNotFound
+	}
+	s.db.ix.remove(num)
+	return s.db.save()
+}
+
+func (s *Store) ComicExists(ctx context.Context, num int) (bool, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	_, ok := s.db.ix.docs[num]
+	return ok, nil
+}
+
+func (s *Store) Get(ctx context.Context, num int) (*data.Comic, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	d, ok := s.db.ix.docs[num]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	c := d.comic
+	return &c, nil
+}
+
+func (s *Store) Latest(ctx context.Context) (*data.Comic, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var latest *document
+	for _, d := range s.db.ix.docs {
+		if latest == nil || d.comic.Number > latest.comic.Number {
+			latest = d
+		}
+	}
+	if latest == nil {
+		return nil, store.ErrNotFound
+	}
+	c := latest.comic
+	return &c, nil
+}
+
+func (s *Store) All(ctx context.Context) ([]data.Comic, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	comics := make([]data.Comic, 0, len(s.db.ix.docs))
+	for _, d := range s.db.ix.docs {
+		comics = append(comics, d.comic)
+	}
+	sort.Slice(comics, func(i, j int) bool { return comics[i].Number < comics[j].Number })
+	return comics, nil
+}
+
+func (s *Store) Search(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*store.Result, error) {
+	if opts == nil {
+		opts = &store.SearchOptions{}
+	}
+
+	count, comics, err := s.search(ctx, query, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	results := make([]*store.Result, len(comics))
+	for i, c := range comics {
+		results[i] = store.NewResult(i, c, opts.Fields)
+	}
+	return count, results, nil
+}
+
+func (s *Store) SearchBatch(ctx context.Context, queries []string, opts []*store.SearchOptions) ([]store.BatchResult, error) {
+	if len(queries) != len(opts) {
+		return nil, fmt.Errorf("expected %d search options, got %d", len(queries), len(opts))
+	}
+
+	results := make([]store.BatchResult, len(queries))
+	for i, q := range queries {
+		if err := checkContext(ctx); err != nil {
+			return nil, fmt.Errorf("search batch failed: %w", err)
+		}
+		results[i].Count, results[i].Results, results[i].Err = s.Search(ctx, q, opts[i])
+	}
+	return results, nil
+}
+
+func (s *Store) SearchComics(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	if opts == nil {
+		opts = &store.SearchOptions{}
+	}
+	if len(opts.Fields) > 0 {
+		return 0, nil, fmt.Errorf("fields are not supported when searching full documents")
+	}
+	return s.search(ctx, query, opts)
+}
+
+// search returns the total number of matches and the requested page of
+// comics
+func (s *Store) search(ctx context.Context, q string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", err)
+	}
+
+	n, err := query.Parse(q)
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w: %w", store.ErrInvalidQuery, err)
+	}
+
+	type hit struct {
+		doc   *document
+		score float64
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	if !s.db.created {
+		return 0, nil, fmt.Errorf("search query failed: %w: no such index", store.ErrUnavailable)
+	}
+
+	switch opts.Mode {
+	case "", store.ModeKeyword:
+	case store.ModeSemantic, store.ModeHybrid:
+		return s.searchSemantic(n, opts)
+	default:
+		return 0, nil, fmt.Errorf("search query failed: %w: unknown search mode %q", store.ErrInvalidQuery, opts.Mode)
+	}
+
+	var hits []hit
+	if n != nil {
+		for num, score := range eval(s.db.ix, n) {
+			hits = append(hits, hit{s.db.ix.docs[num], score})
+		}
+	}
+
+	var less func(a, b hit) bool
+	switch opts.SortBy {
+	case "":
+		less = func(a, b hit) bool {
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			return a.doc.comic.Number > b.doc.comic.Number
+		}
+	case "num", "date":
+		key := func(h hit) int64 {
+			if opts.SortBy == "date" {
+				return h.doc.comic.Date
+			}
+			return int64(h.doc.comic.Number)
+		}
+		less = func(a, b hit) bool {
+			if opts.Ascending {
+				return key(a) < key(b)
+			}
+			return key(a) > key(b)
+		}
+	default:
+		return 0, nil, fmt.Errorf("search query failed: %w: unknown sort field %q", store.ErrInvalidQuery, opts.SortBy)
+	}
+	sort.Slice(hits, func(i, j int) bool { return less(hits[i], hits[j]) })
+
+	limit := store.PageSize(opts)
+	lo := min(max(opts.Offset, 0), len(hits))
+	hi := min(lo+limit, len(hits))
+
+	comics := make([]*data.Comic, 0, hi-lo)
+	for _, h := range hits[lo:hi] {
+		c := h.doc.comic
+		comics = append(comics, &c)
+	}
+	return int64(len(hits)), comics, nil
+}
+
+// searchSemantic ranks comics by the similarity of their embedding to the
+// terms of n, blended with their keyword scores in hybrid mode. The caller
+// must hold the read lock.
+func (s *Store) searchSemantic(n query.Node, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	if opts.SortBy != "" {
+		return 0, nil, fmt.Errorf("search query failed: %w: sort is not supported in %s search", store.ErrInvalidQuery, opts.Mode)
+	}
+
+	text, ranges := query.Semantic(n)
+	if text == "" {
+		return 0, nil, fmt.Errorf("search query failed: %w: %s search requires search terms", store.ErrInvalidQuery, opts.Mode)
+	}
+	k := store.Candidates(opts)
+
+	var filter map[int]float64
+	if len(ranges) > 0 {
+		and := make(query.And, len(ranges))
+		for i, r := range ranges {
+			and[i] = r
+		}
+		filter = eval(s.db.ix, and)
+	}
+
+	vec := s.db.ix.embedder.Embed(text)
+	semantic := make(map[int]float64)
+	for num, d := range s.db.ix.docs {
+		if _, ok := filter[num]; filter != nil && !ok {
+			continue
+		}
+		if sim := embed.Cosine(vec, d.vec); sim > 0 {
+			semantic[num] = sim
+		}
+	}
+
+	scores := top(semantic, k)
+	if opts.Mode == store.ModeHybrid {
+		scores = store.Blend(top(eval(s.db.ix, n), k), scores)
+	}
+	nums := store.Rank(scores)
+
+	limit := store.PageSize(opts)
+	lo := min(max(opts.Offset, 0), len(nums))
+	hi := min(lo+limit, len(nums))
+
+	comics := make([]*data.Comic, 0, hi-lo)
+	for _, num := range nums[lo:hi] {
+		c := s.db.ix.docs[num].comic
+		comics = append(comics, &c)
+	}
+	return int64(len(nums)), comics, nil
+}
+
+// top returns the k highest of scores
+func top(scores map[int]float64, k int) map[int]float64 {
+	if len(scores) <= k {
+		return scores
+	}
+	result := make(map[int]float64, k)
+	for _, num := range store.Rank(scores)[:k] {
+		result[num] = scores[num]
+	}
+	return result
+}
+
+// checkContext maps context errors to the store errors
+func checkContext(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", store.ErrTimeout, err)
+	}
+	return err
+}
+
+func decodeComic(num int, b []byte) (data.Comic, error) {
+	var c data.Comic
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("failed to unmarshal comic %d: %w", num, err)
+	}
+	c.Number = num
+	return c, nil
+}
+
+// patch sets the given string fields of comic num. The caller must hold the
+// write lock.
+func (db *db) patch(num int, fields map[string]string) error {
+	d, ok := db.ix.docs[num]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	c := d.comic
+	if err := store.Apply(&c, fields); err != nil {
+		return fmt.Errorf("failed to patch comic %d: %w", num, err)
+	}
+
+	db.ix.add(c)
+	return db.save()
+}
+
+// save writes all comics to the file of the store, if any. It replaces the
+// file atomically so that it is never partially written.
+func (db *db) save() error {
+	if db.path == "" {
+		return nil
+	}
+
+	comics := make([]data.Comic, 0, len(db.ix.docs))
+	for _, d := range db.ix.docs {
+		comics = append(comics, d.comic)
+	}
+	if err := data.WriteFile(db.path, data.FormatOf(db.path), comics, nil); err != nil {
+		return fmt.Errorf("failed to save comics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/store"
+	"github.com/kencx/sxkcd/store/storetest"
+)
+
+func newTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := New("")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, newTestStore)
+}
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"comics.json", "comics.jsonl"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+
+			s, err := New(path)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if ok, _ := s.CheckIndex(ctx); ok {
+				t.Errorf("expected no index")
+			}
+			if err := s.CreateIndex(ctx); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if err := s.AddBatch(ctx, storetest.Comics); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			// the file is written in the format of its extension
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isLines := bytes.Count(b, []byte("\n")) == len(storetest.Comics); isLines != (data.FormatOf(path) == data.FormatJSONL) {
+				t.Errorf("got %s, want %s", b, data.FormatOf(path))
+			}
+
+			s, err = New(path)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if err := s.CreateIndex(ctx); !errors.Is(err, store.ErrIndexExists) {
+				t.Errorf("got %v, want %v", err, store.ErrIndexExists)
+			}
+			if count, _ := s.Count(ctx); count != len(storetest.Comics) {
+				t.Errorf("got %d, want %d", count, len(storetest.Comics))
+			}
+			if _, results, _ := s.Search(ctx, "python", nil); !reflect.DeepEqual(storetest.Nums(results), []int{353}) {
+				t.Errorf("got %v, want %v", storetest.Nums(results), []int{353})
+			}
+		})
+	}
+}
+
+func TestSemanticSearch(t *testing.T) {
+	ctx := context.Background()
+
+	s := newTestStore(t)
+	storetest.Populate(t, s)
+
+	tests := []struct {
+		name  string
+		query string
+		opts  *store.SearchOptions
+		want  []int
+	}{
+		{"word forms", "sandwiches", &store.SearchOptions{Mode: store.ModeSemantic, Limit: 2}, []int{149, 1597}},
+		{"range", "sandwiches @num: [1000 2000]", &store.SearchOptions{Mode: store.ModeSemantic, Limit: 2}, []int{1597}},
+		{"hybrid", "sandwich", &store.SearchOptions{Mode: store.ModeHybrid, Limit: 2}, []int{149, 1597}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, results, err := s.Search(ctx, tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(storetest.Nums(results), tt.want) {
+				t.Errorf("got %v, want %v", storetest.Nums(results), tt.want)
+			}
+		})
+	}
+
+	for _, opts := range []*store.SearchOptions{
+		{Mode: "fuzzy"},
+		{Mode: store.ModeSemantic, SortBy: "num"},
+	} {
+		if _, _, err := s.Search(ctx, "sandwich", opts); !errors.Is(err, store.ErrInvalidQuery) {
+			t.Errorf("got %v, want %v", err, store.ErrInvalidQuery)
+		}
+	}
+	if _, _, err := s.Search(ctx, "@num: [1 10]", &store.SearchOptions{Mode: store.ModeSemantic}); !errors.Is(err, store.ErrInvalidQuery) {
+		t.Errorf("got %v, want %v", err, store.ErrInvalidQuery)
+	}
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"strings"
+
+	"github.com/kencx/sxkcd/store/query"
+)
+
+// eval returns the scores of all documents matched by n. Scores of And and Or
+// nodes are summed.
+func eval(ix *index, n query.Node) map[int]float64 {
+	switch n := n.(type) {
+	case query.And:
+		var result map[int]float64
+		for _, c := range n {
+			scores := eval(ix, c)
+			if result == nil {
+				result = scores
+				continue
+			}
+
+			for num, s := range result {
+				if cs, ok := scores[num]; ok {
+					result[num] = s + cs
+				} else {
+					delete(result, num)
+				}
+			}
+		}
+		return result
+
+	case query.Or:
+		result := make(map[int]float64)
+		for _, c := range n {
+			for num, s := range eval(ix, c) {
+				result[num] += s
+			}
+		}
+		return result
+
+	case query.Not:
+		exclude := eval(ix, n.Node)
+
+		result := make(map[int]float64)
+		for num := range ix.docs {
+			if _, ok := exclude[num]; !ok {
+				result[num] = 0
+			}
+		}
+		return result
+
+	case query.All:
+		result := make(map[int]float64, len(ix.docs))
+		for num := range ix.docs {
+			result[num] = 0
+		}
+		return result
+
+	case query.Term:
+		return evalTerm(ix, n)
+
+	case query.Range:
+		result := make(map[int]float64)
+		for num, d := range ix.docs {
+			v := float64(d.comic.Number)
+			if n.Field == "date" {
+				v = float64(d.comic.Date)
+			}
+			if v >= n.From && v <= n.To {
+				result[num] = 0
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func evalTerm(ix *index, t query.Term) map[int]float64 {
+	result := make(map[int]float64)
+	field := fieldIndex(t.Field)
+
+	score := func(postings map[int]*termFreq) {
+		for num, tf := range postings {
+			if s := ix.bm25(ix.docs[num], tf, len(postings), field); s > 0 {
+				result[num] += s
+			}
+		}
+	}
+
+	if !t.Prefix {
+		score(ix.postings[t.Text])
+		return result
+	}
+	for term, postings := range ix.postings {
+		if strings.HasPrefix(term, t.Text) {
+			score(postings)
+		}
+	}
+	return result
+}
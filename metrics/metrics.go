@@ -0,0 +1,83 @@
+// Package metrics defines the Prometheus metrics exported at /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "sxkcd"
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	SearchesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "searches_total",
+		Help:      "Total number of successful search queries.",
+	})
+
+	SearchZeroResultsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "search_zero_results_total",
+		Help:      "Total number of search queries that returned no results.",
+	})
+
+	SearchResults = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_results",
+		Help:      "Number of results per search query.",
+		Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+
+	RedisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Redis command latency by command.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 5},
+	}, []string{"command"})
+
+	RedisErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Total number of failed Redis commands by command.",
+	}, []string{"command"})
+
+	WorkerRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "worker_runs_total",
+		Help:      "Total number of worker runs by outcome (added, updated, replaced, failed).",
+	}, []string{"outcome"})
+
+	LatestComic = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "latest_comic_number",
+		Help:      "Number of the latest indexed comic.",
+	})
+
+	FetchErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetch_errors_total",
+		Help:      "Total number of failed upstream requests by source (xkcd, explainxkcd).",
+	}, []string{"source"})
+)
+
+// ObserveSearch records the result count of a successful search
+func ObserveSearch(count int64) {
+	SearchesTotal.Inc()
+	SearchResults.Observe(float64(count))
+	if count == 0 {
+		SearchZeroResultsTotal.Inc()
+	}
+}
@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kencx/sxkcd/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// Search analytics are stored in hourly buckets that expire after the
+// retention window:
+//
+//	{<index>}:analytics:queries:<hour>  sorted set of queries by count
+//	{<index>}:analytics:zero:<hour>     sorted set of zero result queries by count
+//	{<index>}:analytics:stats:<hour>    hash of count, zero and latency_sum
+//
+// The hash tag of the index name keeps all buckets in the same slot of a
+// Redis Cluster so that they can be aggregated with ZUNIONSTORE.
+const hourFormat = "2006010215"
+
+func (r *Client) analyticsPrefix() string {
+	return "{" + r.index + "}:analytics:"
+}
+
+func (r *Client) analyticsKey(kind string, hour time.Time) string {
+	return r.analyticsPrefix() + kind + ":" + hour.UTC().Format(hourFormat)
+}
+
+// RecordQuery records a normalized search query, its number of results and
+// latency in the bucket of the current hour
+func (r *Client) RecordQuery(ctx context.Context, query string, results int64, latency, retention time.Duration) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	now := time.Now()
+	queries := r.analyticsKey("queries", now)
+	zero := r.analyticsKey("zero", now)
+	stats := r.analyticsKey("stats", now)
+
+	pipe := r.rd.Pipeline()
+	pipe.ZIncrBy(ctx, queries, 1, query)
+	pipe.HIncrBy(ctx, stats, "count", 1)
+	pipe.HIncrByFloat(ctx, stats, "latency_sum", latency.Seconds())
+	pipe.Expire(ctx, queries, retention)
+	pipe.Expire(ctx, stats, retention)
+
+	if results == 0 {
+		pipe.ZIncrBy(ctx, zero, 1, query)
+		pipe.HIncrBy(ctx, stats, "zero", 1)
+		pipe.Expire(ctx, zero, retention)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record query: %w", classify(err))
+	}
+	return nil
+}
+
+// TopQueries returns the most frequent queries of the last n hours. If
+// zeroResults is true, only queries without results are counted.
+func (r *Client) TopQueries(ctx context.Context, hours, limit int, zeroResults bool) ([]store.QueryCount, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	kind := "queries"
+	if zeroResults {
+		kind = "zero"
+	}
+
+	keys := make([]string, hours)
+	for i, h := range lastHours(hours) {
+		keys[i] = r.analyticsKey(kind, h)
+	}
+
+	// aggregate into a temporary key to avoid transferring every query
+	tmp := fmt.Sprintf("%stmp:%s:%d", r.analyticsPrefix(), kind, time.Now().UnixNano())
+
+	pipe := r.rd.TxPipeline()
+	pipe.ZUnionStore(ctx, tmp, &redis.ZStore{Keys: keys})
+	top := pipe.ZRevRangeWithScores(ctx, tmp, 0, int64(limit-1))
+	pipe.Del(ctx, tmp)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get top queries: %w", classify(err))
+	}
+
+	result := make([]store.QueryCount, 0, len(top.Val()))
+	for _, z := range top.Val() {
+		result = append(result, store.QueryCount{
+			Query: z.Member.(string),
+			Count: int64(z.Score),
+		})
+	}
+	return result, nil
+}
+
+// QueriesPerHour returns the search statistics of each of the last n hours,
+// oldest first
+func (r *Client) QueriesPerHour(ctx context.Context, hours int) ([]store.HourStats, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	buckets := lastHours(hours)
+
+	pipe := r.rd.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(buckets))
+	for i, h := range buckets {
+		cmds[i] = pipe.HMGet(ctx, r.analyticsKey("stats", h), "count", "zero", "latency_sum")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get hourly stats: %w", classify(err))
+	}
+
+	result := make([]store.HourStats, len(buckets))
+	for i, cmd := range cmds {
+		vals := cmd.Val()
+		s := store.HourStats{
+			Hour:        buckets[i],
+			Queries:     parseInt(vals[0]),
+			ZeroResults: parseInt(vals[1]),
+		}
+		if s.Queries > 0 {
+			latency, _ := strconv.ParseFloat(toString(vals[2]), 64)
+			s.AvgLatency = latency / float64(s.Queries)
+		}
+		result[i] = s
+	}
+	return result, nil
+}
+
+// lastHours returns the start of each of the last n hours including the
+// current hour, oldest first
+func lastHours(n int) []time.Time {
+	now := time.Now().UTC().Truncate(time.Hour)
+
+	hours := make([]time.Time, n)
+	for i := 0; i < n; i++ {
+		hours[i] = now.Add(-time.Duration(n-1-i) * time.Hour)
+	}
+	return hours
+}
+
+func toString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func parseInt(v interface{}) int64 {
+	i, _ := strconv.ParseInt(toString(v), 10, 64)
+	return i
+}
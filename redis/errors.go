@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/kencx/sxkcd/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// classify wraps errors returned by go-redis with one of the store errors so
+// callers can distinguish bad queries from backend failures with errors.Is
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		rerr redis.Error
+		nerr net.Error
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return fmt.Errorf("%w: %w", store.ErrTimeout, err)
+	case errors.As(err, &rerr):
+		// RediSearch query timeout with ON_TIMEOUT FAIL, see CheckConfig
+		if strings.Contains(err.Error(), "Timeout limit was reached") {
+			return fmt.Errorf("%w: %w", store.ErrTimeout, err)
+		}
+		// the index is missing, not the query
+		if isUnknownIndex(err) || isServerState(err) {
+			return fmt.Errorf("%w: %w", store.ErrUnavailable, err)
+		}
+		if isQueryError(err) {
+			return fmt.Errorf("%w: %w", store.ErrInvalidQuery, err)
+		}
+		return err
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return fmt.Errorf("%w: %w", store.ErrTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return err
+	default:
+		// connection refused, closed pool, EOF etc.
+		return fmt.Errorf("%w: %w", store.ErrUnavailable, err)
+	}
+}
+
+// isUnknownIndex reports whether err is the RediSearch error of a missing index
+// or alias, which differs between versions
+func isUnknownIndex(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "no such index") || strings.Contains(msg, "unknown index name")
+}
+
+// serverStates are the prefixes of errors of a server that cannot serve
+// commands for now, such as while loading data or during a failover
+var serverStates = map[string]bool{
+	"LOADING":     true,
+	"NOAUTH":      true,
+	"WRONGPASS":   true,
+	"READONLY":    true,
+	"OOM":         true,
+	"CLUSTERDOWN": true,
+	"MASTERDOWN":  true,
+	"TRYAGAIN":    true,
+}
+
+// isServerState reports whether err is a Redis error caused by the state of
+// the server rather than the command
+func isServerState(err error) bool {
+	prefix, _, _ := strings.Cut(err.Error(), " ")
+	return serverStates[prefix]
+}
+
+// queryErrors are substrings of the RediSearch errors of queries that cannot
+// be parsed
+var queryErrors = []string{
+	"syntax error",
+	"unknown field",
+	"unknown property",
+	"not loaded nor in schema",
+	"bad lower range",
+	"bad upper range",
+	"invalid numeric",
+	"error parsing",
+}
+
+// isQueryError reports whether err is a RediSearch error of an invalid query
+func isQueryError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range queryErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
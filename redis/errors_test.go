@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kencx/sxkcd/store"
+)
+
+// redisError is an error reply of the Redis server
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (redisError) RedisError() {}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want error
+	}{
+		{"LOADING Redis is loading the dataset in memory", store.ErrUnavailable},
+		{"NOAUTH Authentication required.", store.ErrUnavailable},
+		{"WRONGPASS invalid username-password pair or user is disabled.", store.ErrUnavailable},
+		{"READONLY You can't write against a read only replica.", store.ErrUnavailable},
+		{"OOM command not allowed when used memory > 'maxmemory'.", store.ErrUnavailable},
+		{"CLUSTERDOWN The cluster is down", store.ErrUnavailable},
+		{"MASTERDOWN Link with MASTER is down and replica-serve-stale-data is set to 'no'.", store.ErrUnavailable},
+		{"TRYAGAIN Multiple keys request during rehashing of slot", store.ErrUnavailable},
+		{"comics: no such index", store.ErrUnavailable},
+		{"Timeout limit was reached", store.ErrTimeout},
+		{"Syntax error at offset 3 near foo", store.ErrInvalidQuery},
+		{"Unknown field at offset 0 near foo", store.ErrInvalidQuery},
+		{"Bad upper range: foo", store.ErrInvalidQuery},
+		{"WRONGTYPE Operation against a key holding the wrong kind of value", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := classify(redisError(tt.msg))
+			for _, sentinel := range []error{store.ErrUnavailable, store.ErrTimeout, store.ErrInvalidQuery} {
+				if errors.Is(err, sentinel) != (sentinel == tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			}
+		})
+	}
+}
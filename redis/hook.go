@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// metricsHook records the latency and errors of every Redis command
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		observe(cmd, time.Since(start), err)
+		return err
+	}
+}
+
+// commands in a pipeline are recorded individually with the latency of the
+// whole pipeline
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		elapsed := time.Since(start)
+		for _, cmd := range cmds {
+			observe(cmd, elapsed, cmd.Err())
+		}
+		return err
+	}
+}
+
+func observe(cmd redis.Cmder, elapsed time.Duration, err error) {
+	name := strings.ToLower(cmd.Name())
+	metrics.RedisCommandDuration.WithLabelValues(name).Observe(elapsed.Seconds())
+	if err != nil && !errors.Is(err, redis.Nil) {
+		metrics.RedisErrorsTotal.WithLabelValues(name).Inc()
+	}
+}
+
+// loggingHook logs every Redis command at debug level and failed commands at
+// warn level. The request ID of ctx is included, if any.
+type loggingHook struct{}
+
+func (loggingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (loggingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		logCommand(ctx, cmd.Name(), time.Since(start), err)
+		return err
+	}
+}
+
+func (loggingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		logCommand(ctx, "pipeline", time.Since(start), err, "commands", len(cmds))
+		return err
+	}
+}
+
+func logCommand(ctx context.Context, name string, elapsed time.Duration, err error, attrs ...any) {
+	attrs = append(attrs, "cmd", strings.ToLower(name), "duration", elapsed)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.WarnContext(ctx, "redis command failed", append(attrs, "err", err)...)
+		return
+	}
+	slog.DebugContext(ctx, "redis command", attrs...)
+}
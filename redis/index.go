@@ -0,0 +1,483 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// Comics are stored in generations. Each generation has its own index and key
+// prefix, e.g. comics_v2 and comic:v2:, and an alias with the name of the index
+// points to the current one. Generation 0 is the unversioned index that was
+// created before aliases were used.
+
+// time to wait for a new generation to be indexed
+const validateTimeout = 30 * time.Second
+
+// number of keys scanned and deleted at a time
+const deleteBatch = 1000
+
+func (r *Client) indexName(gen int) string {
+	if gen == 0 {
+		return r.index
+	}
+	return fmt.Sprintf("%s_v%d", r.index, gen)
+}
+
+func (r *Client) keyPrefix(gen int) string {
+	if gen == 0 {
+		return r.prefix
+	}
+	return fmt.Sprintf("%sv%d:", r.prefix, gen)
+}
+
+// parseGeneration returns the generation of the index with the given name
+func (r *Client) parseGeneration(name string) (int, error) {
+	if name == r.index {
+		return 0, nil
+	}
+	s, ok := strings.CutPrefix(name, r.index+"_v")
+	gen, err := strconv.Atoi(s)
+	if !ok || err != nil || gen < 1 {
+		return 0, fmt.Errorf("unexpected index name %q", name)
+	}
+	return gen, nil
+}
+
+// CreateIndex creates the first generation of the index and points the alias
+// at it
+func (r *Client) CreateIndex(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.index)
+	defer cancel()
+
+	ok, err := r.CheckIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return store.ErrIndexExists
+	}
+
+	if err := r.createIndex(ctx, 1); err != nil {
+		return err
+	}
+	if err := r.rd.Do(ctx, "FT.ALIASADD", r.index, r.indexName(1)).Err(); err != nil {
+		return fmt.Errorf("failed to add index alias: %w", classify(err))
+	}
+	return r.setSchema(ctx)
+}
+
+// Create JSON index of generation gen with key comic:v[gen]:[num]. A leftover
+// index of a failed reindex is replaced.
+func (r *Client) createIndex(ctx context.Context, gen int) error {
+	args := []interface{}{
+		"FT.CREATE", r.indexName(gen), "ON", "JSON", "PREFIX", "1", r.keyPrefix(gen),
+		"SCHEMA",
+	}
+	args = append(args, schemaArgs(r.schema())...)
+
+	err := r.rd.Do(ctx, args...).Err()
+	if err != nil && err.Error() == "Index already exists" {
+		slog.Warn("replacing leftover index", "index", r.indexName(gen))
+		if err := r.rd.Do(ctx, "FT.DROPINDEX", r.indexName(gen), "DD").Err(); err != nil {
+			return fmt.Errorf("failed to drop index %s: %w", r.indexName(gen), classify(err))
+		}
+		err = r.rd.Do(ctx, args...).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %w", r.indexName(gen), classify(err))
+	}
+	return nil
+}
+
+// Reindex loads comics into a new generation of the index while the current
+// generation continues to serve searches. Once all comics are indexed, the
+// alias is switched to the new generation and the keys of the previous
+// generation are deleted in the background. Keys outside of the index are not
+// touched.
+func (r *Client) Reindex(ctx context.Context, comics []data.Comic) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.index)
+	defer cancel()
+
+	old, err := r.reindex(ctx, comics)
+	if err != nil || old < 0 {
+		return err
+	}
+
+	// the deletion must outlive the context of the request
+	go r.deletePrevious(context.WithoutCancel(ctx), old)
+	return nil
+}
+
+// reindex switches the alias to a new generation of comics and returns the
+// previous generation, or -1 if there was no index
+func (r *Client) reindex(ctx context.Context, comics []data.Comic) (int, error) {
+	old, err := r.generation(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		if err := r.CreateIndex(ctx); err != nil {
+			return -1, err
+		}
+		return -1, r.load(ctx, 1, comics)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to reindex: %w", err)
+	}
+
+	gen := old + 1
+	if err := r.createIndex(ctx, gen); err != nil {
+		return -1, fmt.Errorf("failed to reindex: %w", err)
+	}
+
+	err = r.load(ctx, gen, comics)
+	if err == nil {
+		err = r.validate(ctx, gen, countDistinct(comics))
+	}
+	if err != nil {
+		if derr := r.rd.Do(ctx, "FT.DROPINDEX", r.indexName(gen), "DD").Err(); derr != nil {
+			slog.Warn("failed to drop new index", "index", r.indexName(gen), "err", derr)
+		}
+		return -1, fmt.Errorf("failed to reindex: %w", err)
+	}
+
+	// the new generation is kept on failure, as the unversioned index may
+	// already be dropped
+	if err := r.switchAlias(ctx, old, gen); err != nil {
+		return -1, fmt.Errorf("failed to reindex: %w", err)
+	}
+	slog.Info("switched index", "alias", r.index, "index", r.indexName(gen))
+	return old, r.setSchema(ctx)
+}
+
+// deletePrevious deletes generation gen and logs the outcome
+func (r *Client) deletePrevious(ctx context.Context, gen int) {
+	start := time.Now()
+	n, err := r.deleteGeneration(ctx, gen)
+	if err != nil {
+		slog.Warn("failed to delete previous index", "index", r.indexName(gen), "err", err)
+		return
+	}
+	slog.Info("deleted previous index", "index", r.indexName(gen), "keys", n, "duration", time.Since(start))
+}
+
+// Migrate copies all comics of the current generation into a new generation
+// and deletes the previous one. It converts the unversioned index of older
+// versions, whose keys are not comic numbers, and merges any duplicate
+// documents of the same comic. It returns the number of migrated comics.
+func (r *Client) Migrate(ctx context.Context) (int, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.index)
+	defer cancel()
+
+	comics, err := r.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	old, err := r.reindex(ctx, comics)
+	if err != nil {
+		return 0, err
+	}
+	if old >= 0 {
+		r.deletePrevious(ctx, old)
+	}
+	return len(comics), nil
+}
+
+// All returns all comics of the current generation ordered by number.
+// Duplicate documents of the same comic are merged.
+func (r *Client) All(ctx context.Context) ([]data.Comic, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.index)
+	defer cancel()
+
+	const page = 1000
+
+	var (
+		comics []data.Comic
+		seen   = make(map[int]int)
+	)
+	for offset := 0; ; offset += page {
+		_, docs, err := r.SearchComics(ctx, "*", &store.SearchOptions{
+			SortBy:    "num",
+			Ascending: true,
+			Offset:    offset,
+			Limit:     page,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read comics: %w", err)
+		}
+
+		for _, c := range docs {
+			i, ok := seen[c.Number]
+			if !ok {
+				seen[c.Number] = len(comics)
+				comics = append(comics, *c)
+				continue
+			}
+			fillEmpty(&comics[i], c)
+		}
+		if len(docs) < page {
+			return comics, nil
+		}
+	}
+}
+
+func (r *Client) CheckIndex(ctx context.Context) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	_, err := r.generation(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// generation returns the generation the alias points to. It returns
+// ErrNotFound if there is no index.
+func (r *Client) generation(ctx context.Context) (int, error) {
+	info, err := r.info(ctx, r.index)
+	if err != nil {
+		return 0, err
+	}
+	name, _ := info["index_name"].(string)
+	return r.parseGeneration(name)
+}
+
+// validate waits until all want comics of generation gen are indexed
+func (r *Client) validate(ctx context.Context, gen, want int) error {
+	deadline := time.Now().Add(validateTimeout)
+	for {
+		info, err := r.info(ctx, r.indexName(gen))
+		if err != nil {
+			return err
+		}
+
+		if _, ok := info["hash_indexing_failures"]; ok {
+			failures, err := infoInt(info, "hash_indexing_failures")
+			if err != nil {
+				return err
+			}
+			if failures > 0 {
+				return fmt.Errorf("%d comics could not be indexed", failures)
+			}
+		}
+
+		indexing, err := infoInt(info, "indexing")
+		if err != nil {
+			return err
+		}
+		docs, err := infoInt(info, "num_docs")
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case indexing == 0 && docs == want:
+			return nil
+		case indexing == 0:
+			return fmt.Errorf("indexed %d comics, expected %d", docs, want)
+		case time.Now().After(deadline):
+			return fmt.Errorf("indexing did not finish within %v", validateTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return classify(ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// switchAlias points the alias from generation old to gen
+func (r *Client) switchAlias(ctx context.Context, old, gen int) error {
+	if old != 0 {
+		if err := r.rd.Do(ctx, "FT.ALIASUPDATE", r.index, r.indexName(gen)).Err(); err != nil {
+			return fmt.Errorf("failed to update index alias: %w", classify(err))
+		}
+		return nil
+	}
+
+	// The unversioned index has the name of the alias and must be dropped
+	// before the alias is added, which leaves a short window without an index.
+	// Its prefix also matches the keys of all generations, so it returns
+	// duplicate results while the first generation is loaded.
+	if err := r.rd.Do(ctx, "FT.DROPINDEX", r.index).Err(); err != nil {
+		return fmt.Errorf("failed to drop index %s: %w", r.index, classify(err))
+	}
+	if err := r.rd.Do(ctx, "FT.ALIASADD", r.index, r.indexName(gen)).Err(); err != nil {
+		return fmt.Errorf("failed to add index alias: %w", classify(err))
+	}
+	return nil
+}
+
+// deleteGeneration drops the index of generation gen and deletes its keys in
+// batches with SCAN and UNLINK, instead of FT.DROPINDEX DD which blocks Redis
+// until all keys are deleted. It returns the number of deleted keys.
+func (r *Client) deleteGeneration(ctx context.Context, gen int) (int, error) {
+	// the unversioned index was already dropped when the alias was added
+	if gen != 0 {
+		if err := r.rd.Do(ctx, "FT.DROPINDEX", r.indexName(gen)).Err(); err != nil && !isUnknownIndex(err) {
+			return 0, classify(err)
+		}
+	}
+
+	cluster, ok := r.rd.(*redis.ClusterClient)
+	if !ok {
+		return unlinkPrefix(ctx, r.rd, r.keyPrefix(gen))
+	}
+
+	// SCAN only returns the keys of a single node
+	var total atomic.Int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		n, err := unlinkPrefix(ctx, node, r.keyPrefix(gen))
+		total.Add(int64(n))
+		return err
+	})
+	return int(total.Load()), err
+}
+
+// unlinkPrefix deletes the documents with the key prefix of a generation on a
+// single node
+func unlinkPrefix(ctx context.Context, rd redis.Cmdable, prefix string) (int, error) {
+	var total int
+	iter := rd.Scan(ctx, 0, escapeGlob(prefix)+"*", deleteBatch).Iterator()
+	keys := make([]string, 0, deleteBatch)
+
+	unlink := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		// keys are unlinked one at a time as they may be in different slots
+		pipe := rd.Pipeline()
+		for _, k := range keys {
+			pipe.Unlink(ctx, k)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return classify(err)
+		}
+		total += len(keys)
+		keys = keys[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		// the unversioned prefix also matches the keys of all generations
+		if strings.Contains(strings.TrimPrefix(iter.Val(), prefix), ":") {
+			continue
+		}
+		keys = append(keys, iter.Val())
+		if len(keys) == deleteBatch {
+			if err := unlink(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return total, classify(err)
+	}
+	return total, unlink()
+}
+
+// fillEmpty sets the empty text fields of dst to those of src
+func fillEmpty(dst, src *data.Comic) {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&dst.Title, src.Title},
+		{&dst.Alt, src.Alt},
+		{&dst.Transcript, src.Transcript},
+		{&dst.Explanation, src.Explanation},
+		{&dst.ImgUrl, src.ImgUrl},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+}
+
+// escapeGlob escapes the special characters of a SCAN MATCH pattern in s
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// countDistinct returns the number of distinct comic numbers in comics
+func countDistinct(comics []data.Comic) int {
+	nums := make(map[int]bool, len(comics))
+	for _, c := range comics {
+		nums[c.Number] = true
+	}
+	return len(nums)
+}
+
+// info returns the FT.INFO reply of the index or alias name. It returns
+// ErrNotFound if the index does not exist.
+func (r *Client) info(ctx context.Context, name string) (map[string]interface{}, error) {
+	v, err := r.rd.Do(ctx, "FT.INFO", name).Result()
+	if err != nil {
+		if isUnknownIndex(err) {
+			return nil, fmt.Errorf("index %s: %w", name, store.ErrNotFound)
+		}
+		return nil, classify(err)
+	}
+	return infoMap(v)
+}
+
+// infoMap converts an FT.INFO reply, which is a flat list of field names and
+// values in RESP2 and a map in RESP3
+func infoMap(v interface{}) (map[string]interface{}, error) {
+	info := make(map[string]interface{})
+	switch v := v.(type) {
+	case []interface{}:
+		if len(v)%2 != 0 {
+			return nil, fmt.Errorf("FT.INFO reply could not be parsed")
+		}
+		for i := 0; i < len(v); i += 2 {
+			field, ok := v[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("FT.INFO reply could not be parsed")
+			}
+			info[field] = v[i+1]
+		}
+	case map[interface{}]interface{}:
+		for field, value := range v {
+			info[fmt.Sprint(field)] = value
+		}
+	default:
+		return nil, fmt.Errorf("FT.INFO reply could not be parsed")
+	}
+	return info, nil
+}
+
+// infoInt returns a numeric field of an FT.INFO reply, which is a string,
+// integer or double depending on the field and RediSearch version
+func infoInt(info map[string]interface{}, field string) (int, error) {
+	switch v := info[field].(type) {
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			return int(f), nil
+		}
+	}
+	return 0, fmt.Errorf("FT.INFO field %s could not be parsed: %v", field, info[field])
+}
@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/data"
+)
+
+func TestGeneration(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *Client
+		gen    int
+		index  string
+		prefix string
+	}{
+		{"unversioned", &Client{index: DefaultIndex, prefix: DefaultKeyPrefix}, 0, "comics", "comic:"},
+		{"first", &Client{index: DefaultIndex, prefix: DefaultKeyPrefix}, 1, "comics_v1", "comic:v1:"},
+		{"later", &Client{index: DefaultIndex, prefix: DefaultKeyPrefix}, 12, "comics_v12", "comic:v12:"},
+		{"custom", &Client{index: "staging", prefix: "staging:comic:"}, 3, "staging_v3", "staging:comic:v3:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.client
+			if got := r.indexName(tt.gen); got != tt.index {
+				t.Errorf("got %q, want %q", got, tt.index)
+			}
+			if got := r.keyPrefix(tt.gen); got != tt.prefix {
+				t.Errorf("got %q, want %q", got, tt.prefix)
+			}
+
+			gen, err := r.parseGeneration(tt.index)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if gen != tt.gen {
+				t.Errorf("got %d, want %d", gen, tt.gen)
+			}
+		})
+	}
+
+	r := &Client{index: DefaultIndex, prefix: DefaultKeyPrefix}
+	for _, name := range []string{"comics_v0", "comics_vx", "comics_", "staging_v1", "other"} {
+		if _, err := r.parseGeneration(name); err == nil {
+			t.Errorf("expected err for %q", name)
+		}
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"comic:v1:", "comic:v1:"},
+		{"app*:[x]?", `app\*:\[x\]\?`},
+		{`a\b`, `a\\b`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeGlob(tt.in); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestInfoMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+	}{
+		{"resp2", []interface{}{"index_name", "comics_v2", "num_docs", "2876", "indexing", int64(0)}},
+		{"resp3", map[interface{}]interface{}{"index_name": "comics_v2", "num_docs": float64(2876), "indexing": float64(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := infoMap(tt.reply)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(info["index_name"], "comics_v2") {
+				t.Errorf("got %v, want %v", info["index_name"], "comics_v2")
+			}
+
+			docs, err := infoInt(info, "num_docs")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if docs != 2876 {
+				t.Errorf("got %d, want %d", docs, 2876)
+			}
+			if indexing, err := infoInt(info, "indexing"); err != nil || indexing != 0 {
+				t.Errorf("got %d, %v, want 0", indexing, err)
+			}
+			if _, err := infoInt(info, "missing"); err == nil {
+				t.Errorf("expected err")
+			}
+		})
+	}
+
+	if _, err := infoMap([]interface{}{"index_name"}); err == nil {
+		t.Errorf("expected err")
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	comics := []data.Comic{
+		{Number: 1, Title: "Barrel"},
+		{Number: 2, Title: "Petit Trees"},
+		{Number: 1, Title: "Barrel - Part 1", Explanation: "a boy in a barrel"},
+	}
+
+	if got := countDistinct(comics); got != 2 {
+		t.Errorf("got %d, want %d", got, 2)
+	}
+
+	c := comics[0]
+	fillEmpty(&c, &comics[2])
+	want := data.Comic{Number: 1, Title: "Barrel", Explanation: "a boy in a barrel"}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+}
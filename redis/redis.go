@@ -3,135 +3,359 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/embed"
+	"github.com/kencx/sxkcd/store"
 	"github.com/redis/go-redis/v9"
 )
 
 const (
-	Index     = "comics"
-	KeyPrefix = "comic:"
+	DefaultIndex     = "comics"
+	DefaultKeyPrefix = "comic:"
 )
 
-// Result is identical to data.Comic but excludes the unnecessary
-// transcript and explain attributes that are not rendered but
-// usually very large
-type Result struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	Number int    `json:"num"`
-	Alt    string `json:"alt,omitempty"`
-	ImgUrl string `json:"img_url"`
-	Date   int64  `json:"date"`
-}
+var (
+	_ store.Store          = (*Client)(nil)
+	_ store.Analytics      = (*Client)(nil)
+	_ store.SchemaMigrator = (*Client)(nil)
+	_ store.ConfigChecker  = (*Client)(nil)
+)
 
 type Client struct {
-	ctx context.Context
-	rd  *redis.Client
+	rd redis.UniversalClient
+	// alias of the current generation of the search index
+	index    string
+	prefix   string
+	embedder embed.Embedder
+	timeouts timeouts
+}
+
+// timeouts bound operations in addition to the deadline of the context
+type timeouts struct {
+	search  time.Duration
+	command time.Duration
+	index   time.Duration
+}
+
+// withTimeout returns a copy of ctx that is cancelled after d, or ctx if d is
+// not positive
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// queryTimeout returns the TIMEOUT argument of FT.SEARCH in milliseconds,
+// which aborts the query on the server when ctx expires, or 0 if ctx has no
+// deadline
+func queryTimeout(ctx context.Context) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	// TIMEOUT 0 disables the timeout
+	return max(time.Until(deadline).Milliseconds(), 1)
+}
+
+// CheckConfig returns an error unless RediSearch fails searches that exceed
+// their TIMEOUT. With the default ON_TIMEOUT RETURN, a timed out search returns
+// partial results and counts that cannot be told apart from complete ones.
+func (r *Client) CheckConfig(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	reply, err := r.rd.Do(ctx, "FT.CONFIG", "GET", "ON_TIMEOUT").Result()
+	if err != nil {
+		return fmt.Errorf("failed to get search config: %w", classify(err))
+	}
+	return checkOnTimeout(reply)
+}
+
+// checkOnTimeout returns an error if the FT.CONFIG GET ON_TIMEOUT reply is not
+// FAIL. The reply is a list of name and value pairs, or a map in RESP3.
+func checkOnTimeout(reply interface{}) error {
+	var value interface{}
+	switch v := reply.(type) {
+	case []interface{}:
+		if len(v) == 1 {
+			if pair, ok := v[0].([]interface{}); ok && len(pair) == 2 {
+				value = pair[1]
+			}
+		}
+	case map[interface{}]interface{}:
+		value = v["ON_TIMEOUT"]
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("unexpected search config reply %v", reply)
+	}
+	if !strings.EqualFold(s, "fail") {
+		return fmt.Errorf("search timeouts return partial results: ON_TIMEOUT is %s, "+
+			"set it to FAIL with FT.CONFIG SET ON_TIMEOUT FAIL", s)
+	}
+	return nil
 }
 
-func New(uri string) (*Client, error) {
+// New connects to a single Redis node, Sentinel or Cluster depending on opts
+func New(opts Options) (*Client, error) {
+	uopts, err := opts.universal()
+	if err != nil {
+		return nil, err
+	}
+
+	var rd redis.UniversalClient
+	if opts.Cluster {
+		rd = redis.NewClusterClient(uopts.Cluster())
+	} else {
+		rd = redis.NewUniversalClient(uopts)
+	}
+
 	r := &Client{
-		ctx: context.Background(),
-		rd: redis.NewClient(&redis.Options{
-			Addr:         uri,
-			DialTimeout:  20 * time.Second,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
-		}),
-	}
-	if err := r.rd.Ping(r.ctx).Err(); err != nil {
+		rd:       rd,
+		index:    opts.index(),
+		prefix:   opts.keyPrefix(),
+		embedder: opts.embedder(),
+		timeouts: timeouts{
+			search:  opts.searchTimeout(),
+			command: opts.commandTimeout(),
+			index:   opts.IndexTimeout,
+		},
+	}
+	r.rd.AddHook(metricsHook{})
+	r.rd.AddHook(loggingHook{})
+
+	if err := r.rd.Ping(context.Background()).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to redis database: %w", err)
 	}
 	return r, nil
 }
 
-// Create JSON index with key comic:[num]
-func (r *Client) CreateIndex() error {
-	return r.rd.Do(r.ctx,
-		"FT.CREATE", Index, "ON", "JSON", "PREFIX", "1", KeyPrefix,
-		"SCHEMA",
-		"$.title", "AS", "title", "TEXT", "WEIGHT", "50",
-		"$.alt", "AS", "alt", "TEXT", "WEIGHT", "10",
-		"$.transcript", "AS", "transcript", "TEXT", "WEIGHT", "5",
-		"$.explanation", "AS", "explanation", "TEXT", "WEIGHT", "1",
-		"$.num", "AS", "num", "NUMERIC",
-		"$.date", "AS", "date", "NUMERIC",
-	).Err()
+// Ping returns the round trip time of a PING
+func (r *Client) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if err := r.rd.Ping(ctx).Err(); err != nil {
+		return 0, classify(err)
+	}
+	return time.Since(start), nil
 }
 
-func (r *Client) Reindex() error {
-	err := r.rd.FlushDB(r.ctx).Err()
+// Count returns the number of documents in the current generation of the
+// index, or 0 if there is no index
+func (r *Client) Count(ctx context.Context) (int, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	info, err := r.info(ctx, r.index)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, nil
+	}
 	if err != nil {
-		return err
+		return -1, err
 	}
+	return infoInt(info, "num_docs")
+}
 
-	err = r.CreateIndex()
+// errLegacyKeys is returned when modifying comics of the unversioned index,
+// whose keys are not comic numbers
+var errLegacyKeys = errors.New("index uses the legacy key scheme, run sxkcd migrate")
+
+// key returns the key of comic num in the current generation of the index
+func (r *Client) key(ctx context.Context, num int) (string, error) {
+	gen, err := r.generation(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	if gen == 0 {
+		return "", errLegacyKeys
+	}
+	return r.keyPrefix(gen) + strconv.Itoa(num), nil
 }
 
-func (r *Client) CheckIndex() (bool, error) {
-	indexes, err := r.rd.Do(r.ctx, "FT._LIST").Slice()
+// Add adds comic num, or updates the fields that changed if it already exists
+func (r *Client) Add(ctx context.Context, num int, comic []byte) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	key, err := r.key(ctx, num)
 	if err != nil {
-		return false, err
+		return fmt.Errorf("failed to add comic %d: %w", num, err)
 	}
 
-	if len(indexes) > 0 {
-		if indexes[0] == Index {
-			return true, nil
+	var c data.Comic
+	if err := json.Unmarshal(comic, &c); err != nil {
+		return fmt.Errorf("failed to unmarshal comic %d: %w", num, err)
+	}
+
+	existing, err := r.rd.Do(ctx, getArgs(key)...).Text()
+	if errors.Is(err, redis.Nil) {
+		if err := r.set(ctx, r.rd, key, &c); err != nil {
+			return fmt.Errorf("failed to add comic %d: %w", num, classify(err))
 		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add comic %d: %w", num, classify(err))
+	}
+
+	prev, err := parseComic(existing)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal comic %d: %w", num, err)
+	}
+
+	fields := store.Changes(prev, &c)
+	if len(fields) == 0 {
+		slog.Debug("comic unchanged", "key", key)
+		return nil
 	}
-	return false, nil
+	return r.patch(ctx, key, num, fields)
 }
 
-func (r *Client) Count() (int, error) {
-	count, err := r.rd.DBSize(r.ctx).Result()
+// Replace overwrites the document of comic num, or adds it if it does not exist
+func (r *Client) Replace(ctx context.Context, num int, comic []byte) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	key, err := r.key(ctx, num)
 	if err != nil {
-		return -1, err
+		return fmt.Errorf("failed to replace comic %d: %w", num, err)
+	}
+
+	var c data.Comic
+	if err := json.Unmarshal(comic, &c); err != nil {
+		return fmt.Errorf("failed to unmarshal comic %d: %w", num, err)
+	}
+	if err := r.set(ctx, r.rd, key, &c); err != nil {
+		return fmt.Errorf("failed to replace comic %d: %w", num, classify(err))
+	}
+	return nil
+}
+
+// doer is implemented by clients and pipelines
+type doer interface {
+	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+}
+
+// set stores c with its embedding at key
+func (r *Client) set(ctx context.Context, rd doer, key string, c *data.Comic) error {
+	j, err := json.Marshal(r.document(c))
+	if err != nil {
+		return err
 	}
-	return int(count), nil
+	return rd.Do(ctx, "JSON.SET", key, "$", string(j)).Err()
 }
 
-// Add document if not already exists
-func (r *Client) Add(id int, comic []byte) error {
-	id_str := strconv.Itoa(id - 1)
+// Patch sets the given string fields of comic num in a single transaction
+func (r *Client) Patch(ctx context.Context, num int, fields map[string]string) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
 
-	exists, err := r.rd.Exists(r.ctx, KeyPrefix+id_str).Result()
+	key, err := r.key(ctx, num)
 	if err != nil {
-		if err != redis.Nil {
-			return fmt.Errorf("failed to add comic: %w", err)
+		return fmt.Errorf("failed to patch comic %d: %w", num, err)
+	}
+
+	n, err := r.rd.Exists(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to patch comic %d: %w", num, classify(err))
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return r.patch(ctx, key, num, fields)
+}
+
+// patch sets fields of the document at key and updates its embedding. The
+// document is rewritten in a transaction that fails if it changed meanwhile.
+func (r *Client) patch(ctx context.Context, key string, num int, fields map[string]string) error {
+	err := r.rd.Watch(ctx, func(tx *redis.Tx) error {
+		cmd := redis.NewCmd(ctx, getArgs(key)...)
+		_ = tx.Process(ctx, cmd)
+		existing, err := cmd.Text()
+		if errors.Is(err, redis.Nil) {
+			return store.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		c, err := parseComic(existing)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal comic %d: %w", num, err)
 		}
+		if err := store.Apply(c, fields); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return r.set(ctx, pipe, key, c)
+		})
+		return err
+	}, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return err
 	}
-	if exists != 0 {
-		fmt.Printf("comic %v already present", KeyPrefix+id_str)
-		return nil
+	if err != nil {
+		return fmt.Errorf("failed to patch comic %d: %w", num, classify(err))
+	}
+	return nil
+}
+
+// Delete removes the document of comic num
+func (r *Client) Delete(ctx context.Context, num int) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.command)
+	defer cancel()
+
+	key, err := r.key(ctx, num)
+	if err != nil {
+		return fmt.Errorf("failed to delete comic %d: %w", num, err)
 	}
 
-	err = r.rd.Do(r.ctx, "JSON.SET", KeyPrefix+id_str, "$", string(comic)).Err()
+	n, err := r.rd.Del(ctx, key).Result()
 	if err != nil {
-		return fmt.Errorf("failed to add comic: %w", err)
+		return fmt.Errorf("failed to delete comic %d: %w", num, classify(err))
+	}
+	if n == 0 {
+		return store.ErrNotFound
 	}
 	return nil
 }
 
-func (r *Client) AddBatch(documents []data.Comic) error {
+func (r *Client) AddBatch(ctx context.Context, documents []data.Comic) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.index)
+	defer cancel()
+
+	gen, err := r.generation(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to index: %w", err)
+	}
+	if gen == 0 {
+		return fmt.Errorf("failed to index: %w", errLegacyKeys)
+	}
+	return r.load(ctx, gen, documents)
+}
+
+// load adds all documents to generation gen of the index, keyed by comic
+// number
+func (r *Client) load(ctx context.Context, gen int, documents []data.Comic) error {
+	prefix := r.keyPrefix(gen)
+
 	pipe := r.rd.Pipeline()
-	for i, d := range documents {
-		id := strconv.Itoa(i)
-		j, err := json.Marshal(&d)
-		if err != nil {
+	for _, d := range documents {
+		if err := r.set(ctx, pipe, prefix+strconv.Itoa(d.Number), &d); err != nil {
 			return fmt.Errorf("failed to marshal comic %d: %w", d.Number, err)
 		}
-		pipe.Do(r.ctx, "JSON.SET", KeyPrefix+id, "$", j)
 	}
 
-	_, err := pipe.Exec(r.ctx)
+	_, err := pipe.Exec(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to index: %w", err)
 	}
@@ -139,30 +363,233 @@ func (r *Client) AddBatch(documents []data.Comic) error {
 }
 
 // This checks for existing comic with the comic number $.num in the schema.
-// The comic number is entirely different from the Redis key "comic:id" due to
-// zero indexing and missing comic 404.
-func (r *Client) ComicExists(num int) (bool, error) {
+// Comics are keyed by their number under the prefix of the current index
+// generation, e.g. comic:v2:353.
+func (r *Client) ComicExists(ctx context.Context, num int) (bool, error) {
 	query := fmt.Sprintf("@num: [%d %d]", num, num)
-	count, result, err := r.Search(query)
+	count, result, err := r.Search(ctx, query, nil)
 	if err != nil {
 		return false, fmt.Errorf("failed to find comic %d: %w", num, err)
 	}
 	return (count == 1 && result != nil), nil
 }
 
-// returns slice of 100 results
-func (r *Client) Search(query string) (int64, []*Result, error) {
-	values, err := r.rd.Do(r.ctx,
-		"FT.SEARCH", Index, query,
-		"LIMIT", 0, 100,
-	).Slice()
+// Get retrieves the full document of comic num
+func (r *Client) Get(ctx context.Context, num int) (*data.Comic, error) {
+	query := fmt.Sprintf("@num: [%d %d]", num, num)
+	_, comics, err := r.SearchComics(ctx, query, &store.SearchOptions{Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comic %d: %w", num, err)
+	}
+	if len(comics) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return comics[0], nil
+}
+
+// Latest retrieves the full document of the comic with the highest number
+func (r *Client) Latest(ctx context.Context) (*data.Comic, error) {
+	_, comics, err := r.SearchComics(ctx, "*", &store.SearchOptions{Limit: 1, SortBy: "num"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest comic: %w", err)
+	}
+	if len(comics) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return comics[0], nil
+}
+
+// returns slice of up to 100 results
+func (r *Client) Search(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*store.Result, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.search)
+	defer cancel()
+
+	semantic, err := isSemantic(opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	if semantic {
+		count, comics, err := r.searchSemantic(ctx, query, opts)
+		if err != nil {
+			return 0, nil, err
+		}
+		results := make([]*store.Result, len(comics))
+		for i, c := range comics {
+			results[i] = store.NewResult(i, c, opts.Fields)
+		}
+		return count, results, nil
+	}
+
+	values, err := r.rd.Do(ctx, r.searchArgs(ctx, query, opts)...).Slice()
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+	return parseResults(values, selected(opts))
+}
+
+// SearchBatch runs all queries in a single pipeline. A failed query does not
+// fail the batch, its error is returned in the corresponding BatchResult.
+func (r *Client) SearchBatch(ctx context.Context, queries []string, opts []*store.SearchOptions) ([]store.BatchResult, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.search)
+	defer cancel()
+
+	if len(queries) != len(opts) {
+		return nil, fmt.Errorf("expected %d search options, got %d", len(queries), len(opts))
+	}
+
+	// semantic queries require several round trips each
+	for _, o := range opts {
+		if semantic, err := isSemantic(o); semantic || err != nil {
+			return r.searchEach(ctx, queries, opts), nil
+		}
+	}
+
+	pipe := r.rd.Pipeline()
+	cmds := make([]*redis.Cmd, len(queries))
+	for i, q := range queries {
+		cmds[i] = pipe.Do(ctx, r.searchArgs(ctx, q, opts[i])...)
+	}
+
+	// errors returned by Redis are specific to a query, all other errors
+	// (e.g. network) fail the whole batch
+	_, err := pipe.Exec(ctx)
+	var rerr redis.Error
+	if err != nil && !errors.As(err, &rerr) {
+		return nil, fmt.Errorf("search batch failed: %w", classify(err))
+	}
+
+	results := make([]store.BatchResult, len(cmds))
+	for i, cmd := range cmds {
+		values, err := cmd.Slice()
+		if err != nil {
+			results[i].Err = fmt.Errorf("search query failed: %w", classify(err))
+			continue
+		}
+		results[i].Count, results[i].Results, results[i].Err = parseResults(values, selected(opts[i]))
+	}
+	return results, nil
+}
+
+// searchEach runs all queries sequentially
+func (r *Client) searchEach(ctx context.Context, queries []string, opts []*store.SearchOptions) []store.BatchResult {
+	results := make([]store.BatchResult, len(queries))
+	for i, q := range queries {
+		results[i].Count, results[i].Results, results[i].Err = r.Search(ctx, q, opts[i])
+	}
+	return results
+}
+
+// SearchComics is identical to Search but returns the full documents,
+// including transcript and explanation
+func (r *Client) SearchComics(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.search)
+	defer cancel()
+
+	if opts != nil && len(opts.Fields) > 0 {
+		return 0, nil, fmt.Errorf("fields are not supported when searching full documents")
+	}
+	semantic, err := isSemantic(opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	if semantic {
+		return r.searchSemantic(ctx, query, opts)
+	}
+
+	// all fields except the embedding
+	o := store.SearchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Fields = comicFields
+
+	values, err := r.rd.Do(ctx, r.searchArgs(ctx, query, &o)...).Slice()
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+
+	var comics []*data.Comic
+	count, err := eachResult(values, func(_ int, doc []interface{}) error {
+		var c data.Comic
+		if err := setFields(&c, doc); err != nil {
+			return err
+		}
+		comics = append(comics, &c)
+		return nil
+	})
 	if err != nil {
-		return 0, nil, fmt.Errorf("search query failed: %w", err)
+		return 0, nil, err
+	}
+	return count, comics, nil
+}
+
+// searchArgs returns the FT.SEARCH arguments of query. Only opts.Fields or
+// defaultFields are returned instead of the whole document.
+func (r *Client) searchArgs(ctx context.Context, query string, opts *store.SearchOptions) []interface{} {
+	if opts == nil {
+		opts = &store.SearchOptions{}
+	}
+	limit := store.PageSize(opts)
+
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = defaultFields
 	}
+	// num is always returned to identify the comic
+	fields = append([]string{"num"}, fields...)
 
+	args := []interface{}{"FT.SEARCH", r.index, query, "RETURN", 3 * len(fields)}
+	for _, f := range fields {
+		args = append(args, "$."+f, "AS", f)
+	}
+	if opts.SortBy != "" {
+		order := "DESC"
+		if opts.Ascending {
+			order = "ASC"
+		}
+		args = append(args, "SORTBY", opts.SortBy, order)
+	}
+	if ms := queryTimeout(ctx); ms > 0 {
+		args = append(args, "TIMEOUT", ms)
+	}
+	if opts.CountOnly {
+		// LIMIT 0 0 only counts the results
+		return append(args, "LIMIT", 0, 0)
+	}
+	return append(args, "LIMIT", opts.Offset, limit)
+}
+
+// selected returns the fields selected by opts, if any
+func selected(opts *store.SearchOptions) []string {
+	if opts == nil {
+		return nil
+	}
+	return opts.Fields
+}
+
+// parseResults returns the results of a search that returned fields
+func parseResults(values []interface{}, fields []string) (int64, []*store.Result, error) {
+	var results []*store.Result
+	count, err := eachResult(values, func(i int, doc []interface{}) error {
+		var c data.Comic
+		if err := setFields(&c, doc); err != nil {
+			return err
+		}
+		results = append(results, store.NewResult(i, &c, fields))
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, results, nil
+}
+
+func eachResult(values []interface{}, decode func(int, []interface{}) error) (int64, error) {
+	if len(values) == 0 {
+		return 0, fmt.Errorf("search result could not be parsed")
+	}
 	count := values[0].(int64)
 
-	var results []*Result
 	for i, v := range values[1:] {
 
 		// skip comic:[id]
@@ -170,19 +597,44 @@ func (r *Client) Search(query string) (int64, []*Result, error) {
 			continue
 		}
 
-		// ["$", data]
 		sl, ok := v.([]interface{})
 		if !ok {
-			return 0, nil, fmt.Errorf("search result could not be parsed")
+			return 0, fmt.Errorf("search result could not be parsed")
 		}
-		b := []byte(sl[1].(string))
 
-		var res Result
-		if err := json.Unmarshal(b, &res); err != nil {
-			return 0, nil, fmt.Errorf("search result could not be unmarshaled: %w", err)
+		if err := decode(i, sl); err != nil {
+			return 0, fmt.Errorf("search result could not be unmarshaled: %w", err)
 		}
-		res.Id = i
-		results = append(results, &res)
 	}
-	return count, results, nil
+	return count, nil
+}
+
+// setFields populates c from the [field, value...] pairs returned with RETURN
+func setFields(c *data.Comic, doc []interface{}) error {
+	for j := 0; j+1 < len(doc); j += 2 {
+		field, _ := doc[j].(string)
+		value, _ := doc[j+1].(string)
+
+		var err error
+		switch field {
+		case "num":
+			c.Number, err = strconv.Atoi(value)
+		case "date":
+			c.Date, err = strconv.ParseInt(value, 10, 64)
+		case "title":
+			c.Title = value
+		case "alt":
+			c.Alt = value
+		case "img_url":
+			c.ImgUrl = value
+		case "transcript":
+			c.Transcript = value
+		case "explanation":
+			c.Explanation = value
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", field, value, err)
+		}
+	}
+	return nil
 }
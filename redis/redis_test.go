@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kencx/sxkcd/store"
+)
+
+func TestSearchTimeout(t *testing.T) {
+	r := &Client{index: DefaultIndex}
+	ctx := context.Background()
+
+	timeoutArg := func(args []interface{}) (int64, bool) {
+		for i, a := range args {
+			if a == "TIMEOUT" {
+				return args[i+1].(int64), true
+			}
+		}
+		return 0, false
+	}
+
+	if _, ok := timeoutArg(r.searchArgs(ctx, "foo", nil)); ok {
+		t.Errorf("expected no TIMEOUT without deadline")
+	}
+
+	tctx, cancel := withTimeout(ctx, 2*time.Second)
+	defer cancel()
+	ms, ok := timeoutArg(r.searchArgs(tctx, "foo", &store.SearchOptions{Limit: 5}))
+	if !ok || ms <= 0 || ms > 2000 {
+		t.Errorf("got TIMEOUT %d, want between 1 and 2000", ms)
+	}
+
+	// an expired deadline must not disable the server timeout
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	if ms, _ := timeoutArg(r.searchArgs(expired, "foo", nil)); ms != 1 {
+		t.Errorf("got TIMEOUT %d, want 1", ms)
+	}
+
+	if same, _ := withTimeout(ctx, -1); same != ctx {
+		t.Errorf("expected negative timeout to be disabled")
+	}
+}
+
+func TestSearchArgsCountOnly(t *testing.T) {
+	r := &Client{index: DefaultIndex}
+
+	args := r.searchArgs(context.Background(), "foo", &store.SearchOptions{Offset: 20, Limit: 5, CountOnly: true})
+	got := args[len(args)-3:]
+	if want := []interface{}{"LIMIT", 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckOnTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		wantErr bool
+	}{
+		{"fail", []interface{}{[]interface{}{"ON_TIMEOUT", "fail"}}, false},
+		{"fail resp3", map[interface{}]interface{}{"ON_TIMEOUT": "fail"}, false},
+		// partial results of timed out searches would be returned as complete
+		{"return", []interface{}{[]interface{}{"ON_TIMEOUT", "return"}}, true},
+		{"return resp3", map[interface{}]interface{}{"ON_TIMEOUT": "return"}, true},
+		{"unexpected", []interface{}{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkOnTimeout(tt.reply); (err != nil) != tt.wantErr {
+				t.Errorf("got err %v, want err %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseResults(t *testing.T) {
+	values := []interface{}{int64(1), "comic:v1:353", []interface{}{"num", "353", "title", "Python"}}
+
+	count, results, err := parseResults(values, []string{"title"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if count != 1 || len(results) != 1 {
+		t.Fatalf("got count %d and %d results, want 1", count, len(results))
+	}
+	b, err := json.Marshal(results[0])
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// only the selected fields are encoded
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(got) != 3 || got["num"] != 353.0 || got["title"] != "Python" {
+		t.Errorf("got %s", b)
+	}
+}
@@ -0,0 +1,191 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/kencx/sxkcd/store"
+	"github.com/redis/go-redis/v9"
+)
+
+// schemaVersion must be incremented with every change to schema, and a
+// migration to the new version added to migrations
+const schemaVersion = 2
+
+// schema contains the field definitions of the SCHEMA argument of FT.CREATE,
+// except for the vector field whose dimensions depend on the embedder
+var schema = [][]string{
+	{"$.title", "AS", "title", "TEXT", "WEIGHT", "50"},
+	{"$.alt", "AS", "alt", "TEXT", "WEIGHT", "10"},
+	{"$.transcript", "AS", "transcript", "TEXT", "WEIGHT", "5"},
+	{"$.explanation", "AS", "explanation", "TEXT", "WEIGHT", "1"},
+	{"$.num", "AS", "num", "NUMERIC"},
+	{"$.date", "AS", "date", "NUMERIC"},
+}
+
+// migration upgrades the index schema from the previous version to version.
+// Additive changes are applied with FT.ALTER from the field definitions in add,
+// which are indexed in the background. Any other change requires rebuild,
+// which copies all stored comics into a new generation of the index.
+type migration struct {
+	version int
+	add     [][]string
+	rebuild bool
+}
+
+// migrations are ordered by version, e.g.
+//
+//	{version: 2, add: [][]string{{"$.tags", "AS", "tags", "TAG"}}},
+//	{version: 3, rebuild: true},
+var migrations = []migration{
+	// embeddings of all comics for semantic search
+	{version: 2, rebuild: true},
+}
+
+// schema returns the field definitions of the index
+func (r *Client) schema() [][]string {
+	vector := []string{
+		"$.embedding", "AS", "embedding", "VECTOR", "HNSW", "6",
+		"TYPE", "FLOAT32", "DIM", strconv.Itoa(r.embedder.Dim()), "DISTANCE_METRIC", "COSINE",
+	}
+	return append(append([][]string{}, schema...), vector)
+}
+
+// schemaHash returns a hash of the field definitions to detect changes that
+// are not accompanied by a new version, such as a different embedder
+func schemaHash(fields [][]string) string {
+	h := sha256.New()
+	for _, field := range fields {
+		h.Write([]byte(strings.Join(field, " ") + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// schemaArgs returns field definitions as command arguments
+func schemaArgs(fields [][]string) []interface{} {
+	var args []interface{}
+	for _, field := range fields {
+		for _, a := range field {
+			args = append(args, a)
+		}
+	}
+	return args
+}
+
+// schemaKey is the hash that stores the version and hash of the schema of the
+// index
+func (r *Client) schemaKey() string {
+	return "{" + r.index + "}:schema"
+}
+
+func (r *Client) setSchema(ctx context.Context) error {
+	err := r.rd.HSet(ctx, r.schemaKey(), "version", schemaVersion, "hash", schemaHash(r.schema())).Err()
+	if err != nil {
+		return fmt.Errorf("failed to store schema version: %w", classify(err))
+	}
+	return nil
+}
+
+// storedSchema returns the version and hash of the schema of the index, or 0
+// if they were not stored
+func (r *Client) storedSchema(ctx context.Context) (int, string, error) {
+	vals, err := r.rd.HMGet(ctx, r.schemaKey(), "version", "hash").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, "", fmt.Errorf("failed to get schema version: %w", classify(err))
+	}
+
+	version, hash := toString(vals[0]), toString(vals[1])
+	if version == "" {
+		return 0, "", nil
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid schema version %q", version)
+	}
+	return v, hash, nil
+}
+
+// MigrateSchema runs the migrations from the stored schema version of the index
+// to schemaVersion. It refuses to run if the index is newer than this version
+// or the schema was changed without a new version.
+func (r *Client) MigrateSchema(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.index)
+	defer cancel()
+
+	gen, err := r.generation(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	version, hash, err := r.storedSchema(ctx)
+	if err != nil {
+		return err
+	}
+	// indexes created before schema versioning have the schema of version 1
+	if version == 0 {
+		version = 1
+	}
+
+	switch {
+	case version > schemaVersion:
+		return fmt.Errorf("index schema version %d is newer than the supported version %d, please upgrade sxkcd", version, schemaVersion)
+	case version == schemaVersion && hash != "" && hash != schemaHash(r.schema()):
+		return fmt.Errorf("index schema differs from schema version %d without a migration, include --reindex to rebuild the index from a file", version)
+	case version == schemaVersion:
+		if hash == "" {
+			return r.setSchema(ctx)
+		}
+		return nil
+	}
+
+	pending, rebuild := pendingMigrations(migrations, version, schemaVersion)
+	if rebuild {
+		slog.Info("rebuilding index for new schema", "from", version, "to", schemaVersion)
+		comics, err := r.All(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate schema: %w", err)
+		}
+		// reindex stores the new schema version
+		return r.Reindex(ctx, comics)
+	}
+
+	for _, m := range pending {
+		slog.Info("migrating index schema", "index", r.indexName(gen), "version", m.version)
+		args := []interface{}{"FT.ALTER", r.indexName(gen), "SCHEMA", "ADD"}
+		args = append(args, schemaArgs(m.add)...)
+		if err := r.rd.Do(ctx, args...).Err(); err != nil {
+			return fmt.Errorf("failed to migrate schema to version %d: %w", m.version, classify(err))
+		}
+	}
+	return r.setSchema(ctx)
+}
+
+// pendingMigrations returns the migrations of ms from version to target and
+// whether any of them, or a missing migration, requires a rebuild of the index
+func pendingMigrations(ms []migration, version, target int) ([]migration, bool) {
+	var pending []migration
+	for _, m := range ms {
+		if m.version > version && m.version <= target {
+			pending = append(pending, m)
+		}
+	}
+
+	if len(pending) != target-version {
+		return pending, true
+	}
+	for _, m := range pending {
+		if m.rebuild {
+			return pending, true
+		}
+	}
+	return pending, false
+}
@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/kencx/sxkcd/embed"
+)
+
+func TestPendingMigrations(t *testing.T) {
+	ms := []migration{
+		{version: 2, add: [][]string{{"$.tags", "AS", "tags", "TAG"}}},
+		{version: 3, add: [][]string{{"$.safe_title", "AS", "safe_title", "TEXT"}}},
+		{version: 4, rebuild: true},
+		{version: 6, add: [][]string{{"$.year", "AS", "year", "NUMERIC"}}},
+	}
+
+	tests := []struct {
+		name    string
+		version int
+		target  int
+		pending int
+		rebuild bool
+	}{
+		{"current", 3, 3, 0, false},
+		{"additive", 1, 3, 2, false},
+		{"single", 2, 3, 1, false},
+		{"rebuild", 1, 4, 3, true},
+		{"after rebuild", 4, 4, 0, false},
+		{"missing migration", 4, 6, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending, rebuild := pendingMigrations(ms, tt.version, tt.target)
+			if len(pending) != tt.pending {
+				t.Errorf("got %d pending migrations, want %d", len(pending), tt.pending)
+			}
+			if rebuild != tt.rebuild {
+				t.Errorf("got rebuild %v, want %v", rebuild, tt.rebuild)
+			}
+		})
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	r := &Client{embedder: embed.NewHashed(embed.DefaultDim)}
+	hash := schemaHash(r.schema())
+	if len(hash) != 16 {
+		t.Errorf("got %q, want 16 hex characters", hash)
+	}
+	if got := len(schemaArgs(r.schema())); got != 44 {
+		t.Errorf("got %d args, want %d", got, 44)
+	}
+
+	// the dimensions of the embedder are part of the schema
+	other := &Client{embedder: embed.NewHashed(256)}
+	if schemaHash(other.schema()) == hash {
+		t.Errorf("expected hash to change with embedder")
+	}
+
+	orig := schema
+	t.Cleanup(func() { schema = orig })
+
+	schema = append(append([][]string{}, orig...), []string{"$.tags", "AS", "tags", "TAG"})
+	if schemaHash(r.schema()) == hash {
+		t.Errorf("expected hash to change with schema")
+	}
+}
@@ -0,0 +1,302 @@
+package redis
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/embed"
+	"github.com/kencx/sxkcd/store"
+	"github.com/kencx/sxkcd/store/query"
+	"github.com/redis/go-redis/v9"
+)
+
+// document is the JSON document of a comic stored in Redis
+type document struct {
+	data.Comic
+	// Embedding is omitted for comics without text, as zero vectors cannot be
+	// indexed with the cosine distance
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+func (r *Client) document(c *data.Comic) *document {
+	d := &document{Comic: *c}
+
+	vec := r.embedder.Embed(embed.ComicText(c))
+	for _, v := range vec {
+		if v != 0 {
+			d.Embedding = vec
+			break
+		}
+	}
+	return d
+}
+
+// defaultFields are returned by Search if no fields are requested, which
+// avoids transferring the embeddings of all results
+var defaultFields = []string{"title", "alt", "img_url", "date"}
+
+// comicFields are all fields of a comic except num, which are read instead of
+// the whole document so that embeddings are only transferred by FT.SEARCH
+// with KNN
+var comicFields = []string{"title", "alt", "transcript", "img_url", "explanation", "date"}
+
+// getArgs returns the JSON.GET arguments of the comic fields of the document
+// at key
+func getArgs(key string) []interface{} {
+	args := []interface{}{"JSON.GET", key, "$.num"}
+	for _, f := range comicFields {
+		args = append(args, "$."+f)
+	}
+	return args
+}
+
+// parseComic returns the comic of a JSON.GET reply of getArgs, which maps each
+// path to its matches
+func parseComic(reply string) (*data.Comic, error) {
+	var paths map[string][]json.RawMessage
+	if err := json.Unmarshal([]byte(reply), &paths); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(paths))
+	for path, matches := range paths {
+		if len(matches) > 0 {
+			fields[strings.TrimPrefix(path, "$.")] = matches[0]
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var c data.Comic
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// isSemantic reports whether opts select semantic or hybrid search
+func isSemantic(opts *store.SearchOptions) (bool, error) {
+	if opts == nil {
+		return false, nil
+	}
+	switch opts.Mode {
+	case "", store.ModeKeyword:
+		return false, nil
+	case store.ModeSemantic, store.ModeHybrid:
+		return true, nil
+	}
+	return false, fmt.Errorf("search query failed: %w: unknown search mode %q", store.ErrInvalidQuery, opts.Mode)
+}
+
+// searchSemantic returns the total number of candidates and the requested page
+// of comics ranked by the similarity of their embedding to the terms of q. In
+// hybrid mode, the similarities are blended with the keyword scores of q.
+func (r *Client) searchSemantic(ctx context.Context, q string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	if opts.SortBy != "" {
+		return 0, nil, fmt.Errorf("search query failed: %w: sort is not supported in %s search", store.ErrInvalidQuery, opts.Mode)
+	}
+
+	n, err := query.Parse(q)
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w: %w", store.ErrInvalidQuery, err)
+	}
+	text, ranges := query.Semantic(n)
+	if text == "" {
+		return 0, nil, fmt.Errorf("search query failed: %w: %s search requires search terms", store.ErrInvalidQuery, opts.Mode)
+	}
+	k := store.Candidates(opts)
+
+	pipe := r.rd.Pipeline()
+	knn := pipe.Do(ctx, r.knnArgs(ctx, r.embedder.Embed(text), ranges, k)...)
+	var keyword *redis.Cmd
+	if opts.Mode == store.ModeHybrid {
+		args := []interface{}{"FT.SEARCH", r.index, q, "WITHSCORES", "RETURN", 1, "num"}
+		if ms := queryTimeout(ctx); ms > 0 {
+			args = append(args, "TIMEOUT", ms)
+		}
+		keyword = pipe.Do(ctx, append(args, "LIMIT", 0, k)...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+
+	values, err := knn.Slice()
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+	distances, err := parseScores(values, false, "vector_score")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// cosine distances range from 0 to 2
+	scores := make(map[int]float64, len(distances))
+	for num, d := range distances {
+		if sim := 1 - d; sim > 0 {
+			scores[num] = sim
+		}
+	}
+
+	if keyword != nil {
+		values, err := keyword.Slice()
+		if err != nil {
+			return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+		}
+		kw, err := parseScores(values, true, "")
+		if err != nil {
+			return 0, nil, err
+		}
+		scores = store.Blend(kw, scores)
+	}
+	nums := store.Rank(scores)
+
+	limit := store.PageSize(opts)
+	lo := min(max(opts.Offset, 0), len(nums))
+	hi := min(lo+limit, len(nums))
+	if lo == hi {
+		return int64(len(nums)), nil, nil
+	}
+
+	comics, err := r.getAll(ctx, nums[lo:hi])
+	if err != nil {
+		return 0, nil, err
+	}
+	return int64(len(nums)), comics, nil
+}
+
+// knnArgs returns the FT.SEARCH arguments of the k nearest neighbours of vec
+// that match all ranges
+func (r *Client) knnArgs(ctx context.Context, vec []float32, ranges []query.Range, k int) []interface{} {
+	filter := "*"
+	if len(ranges) > 0 {
+		var sb strings.Builder
+		sb.WriteString("(")
+		for i, rg := range ranges {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			fmt.Fprintf(&sb, "@%s:[%s %s]", rg.Field, formatBound(rg.From), formatBound(rg.To))
+		}
+		sb.WriteString(")")
+		filter = sb.String()
+	}
+
+	q := fmt.Sprintf("%s=>[KNN %d @embedding $vec AS vector_score]", filter, k)
+	args := []interface{}{
+		"FT.SEARCH", r.index, q,
+		"PARAMS", 2, "vec", vectorBlob(vec),
+		"RETURN", 2, "num", "vector_score",
+		"SORTBY", "vector_score", "ASC",
+	}
+	if ms := queryTimeout(ctx); ms > 0 {
+		args = append(args, "TIMEOUT", ms)
+	}
+	return append(args, "LIMIT", 0, k, "DIALECT", 2)
+}
+
+func formatBound(f float64) string {
+	switch {
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsInf(f, 1):
+		return "+inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// vectorBlob returns vec as little-endian FLOAT32 bytes
+func vectorBlob(vec []float32) string {
+	b := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return string(b)
+}
+
+// parseScores returns the scores of an FT.SEARCH reply by comic number. The
+// score is the document score if withScores is set, or else the returned field.
+func parseScores(values []interface{}, withScores bool, field string) (map[int]float64, error) {
+	stride := 2
+	if withScores {
+		stride = 3
+	}
+	if len(values) == 0 || (len(values)-1)%stride != 0 {
+		return nil, fmt.Errorf("search result could not be parsed")
+	}
+
+	scores := make(map[int]float64, (len(values)-1)/stride)
+	for i := 1; i < len(values); i += stride {
+		doc, ok := values[i+stride-1].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("search result could not be parsed")
+		}
+
+		var num int
+		score := math.NaN()
+		if withScores {
+			score, _ = strconv.ParseFloat(toString(values[i+1]), 64)
+		}
+		for j := 0; j+1 < len(doc); j += 2 {
+			switch toString(doc[j]) {
+			case "num":
+				num, _ = strconv.Atoi(toString(doc[j+1]))
+			case field:
+				score, _ = strconv.ParseFloat(toString(doc[j+1]), 64)
+			}
+		}
+		if num == 0 || math.IsNaN(score) {
+			return nil, fmt.Errorf("search result could not be parsed")
+		}
+		scores[num] = score
+	}
+	return scores, nil
+}
+
+// getAll returns the comics of nums in order
+func (r *Client) getAll(ctx context.Context, nums []int) ([]*data.Comic, error) {
+	gen, err := r.generation(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("search query failed: %w", err)
+	}
+	if gen == 0 {
+		return nil, fmt.Errorf("search query failed: %w", errLegacyKeys)
+	}
+	prefix := r.keyPrefix(gen)
+
+	pipe := r.rd.Pipeline()
+	cmds := make([]*redis.Cmd, len(nums))
+	for i, num := range nums {
+		cmds[i] = pipe.Do(ctx, getArgs(prefix+strconv.Itoa(num))...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+
+	comics := make([]*data.Comic, 0, len(nums))
+	for i, cmd := range cmds {
+		doc, err := cmd.Text()
+		// deleted since the search
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("search query failed: %w", classify(err))
+		}
+
+		c, err := parseComic(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal comic %d: %w", nums[i], err)
+		}
+		comics = append(comics, c)
+	}
+	return comics, nil
+}
@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/store/query"
+)
+
+func TestParseScores(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []interface{}
+		withScores bool
+		field      string
+		want       map[int]float64
+		wantErr    bool
+	}{
+		{
+			name: "field",
+			values: []interface{}{int64(2),
+				"comic:v1:149", []interface{}{"num", "149", "vector_score", "0.25"},
+				"comic:v1:303", []interface{}{"vector_score", "0.5", "num", "303"},
+			},
+			field: "vector_score",
+			want:  map[int]float64{149: 0.25, 303: 0.5},
+		},
+		{
+			name: "with scores",
+			values: []interface{}{int64(1),
+				"comic:v1:149", "3.5", []interface{}{"num", "149"},
+			},
+			withScores: true,
+			want:       map[int]float64{149: 3.5},
+		},
+		{
+			name:   "empty",
+			values: []interface{}{int64(0)},
+			field:  "vector_score",
+			want:   map[int]float64{},
+		},
+		{
+			name: "missing score",
+			values: []interface{}{int64(1),
+				"comic:v1:149", []interface{}{"num", "149"},
+			},
+			field:   "vector_score",
+			wantErr: true,
+		},
+		{
+			name: "truncated",
+			values: []interface{}{int64(1),
+				"comic:v1:149", "3.5",
+			},
+			withScores: true,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScores(tt.values, tt.withScores, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want err %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKnnArgs(t *testing.T) {
+	r := &Client{index: DefaultIndex}
+	ranges := []query.Range{
+		{Field: "num", From: 100, To: math.Inf(1)},
+		{Field: "date", From: math.Inf(-1), To: 1.5},
+	}
+
+	args := r.knnArgs(context.Background(), []float32{1, 0}, ranges, 10)
+	want := "(@num:[100 +inf] @date:[-inf 1.5])=>[KNN 10 @embedding $vec AS vector_score]"
+	if args[2] != want {
+		t.Errorf("got query %q, want %q", args[2], want)
+	}
+	if blob := args[6].(string); blob != "\x00\x00\x80\x3f\x00\x00\x00\x00" {
+		t.Errorf("got vector %q", blob)
+	}
+
+	args = r.knnArgs(context.Background(), []float32{1, 0}, nil, 10)
+	if want := "*=>[KNN 10 @embedding $vec AS vector_score]"; args[2] != want {
+		t.Errorf("got query %q, want %q", args[2], want)
+	}
+}
+
+func TestParseComic(t *testing.T) {
+	// JSON.GET of several paths, without the embedding
+	reply := `{"$.num":[353],"$.title":["Python"],"$.alt":["I wrote 20 short programs in Python yesterday."],"$.transcript":[],"$.img_url":["y"],"$.explanation":[""],"$.date":[1196294400]}`
+
+	got, err := parseComic(reply)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := &data.Comic{
+		Number: 353,
+		Title:  "Python",
+		Alt:    "I wrote 20 short programs in Python yesterday.",
+		ImgUrl: "y",
+		Date:   1196294400,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, arg := range getArgs("comic:v2:353") {
+		if arg == "$" || arg == "$.embedding" {
+			t.Errorf("expected embedding to be excluded, got %v", arg)
+		}
+	}
+}
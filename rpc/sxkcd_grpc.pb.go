@@ -0,0 +1,275 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: sxkcd.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Sxkcd_Search_FullMethodName         = "/sxkcd.Sxkcd/Search"
+	Sxkcd_GetComic_FullMethodName       = "/sxkcd.Sxkcd/GetComic"
+	Sxkcd_StreamComics_FullMethodName   = "/sxkcd.Sxkcd/StreamComics"
+	Sxkcd_WatchNewComics_FullMethodName = "/sxkcd.Sxkcd/WatchNewComics"
+)
+
+// SxkcdClient is the client API for Sxkcd service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SxkcdClient interface {
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	GetComic(ctx context.Context, in *GetComicRequest, opts ...grpc.CallOption) (*Comic, error)
+	StreamComics(ctx context.Context, in *StreamComicsRequest, opts ...grpc.CallOption) (Sxkcd_StreamComicsClient, error)
+	WatchNewComics(ctx context.Context, in *WatchNewComicsRequest, opts ...grpc.CallOption) (Sxkcd_WatchNewComicsClient, error)
+}
+
+type sxkcdClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSxkcdClient(cc grpc.ClientConnInterface) SxkcdClient {
+	return &sxkcdClient{cc}
+}
+
+func (c *sxkcdClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Sxkcd_Search_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sxkcdClient) GetComic(ctx context.Context, in *GetComicRequest, opts ...grpc.CallOption) (*Comic, error) {
+	out := new(Comic)
+	err := c.cc.Invoke(ctx, Sxkcd_GetComic_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sxkcdClient) StreamComics(ctx context.Context, in *StreamComicsRequest, opts ...grpc.CallOption) (Sxkcd_StreamComicsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sxkcd_ServiceDesc.Streams[0], Sxkcd_StreamComics_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sxkcdStreamComicsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Sxkcd_StreamComicsClient interface {
+	Recv() (*Comic, error)
+	grpc.ClientStream
+}
+
+type sxkcdStreamComicsClient struct {
+	grpc.ClientStream
+}
+
+func (x *sxkcdStreamComicsClient) Recv() (*Comic, error) {
+	m := new(Comic)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sxkcdClient) WatchNewComics(ctx context.Context, in *WatchNewComicsRequest, opts ...grpc.CallOption) (Sxkcd_WatchNewComicsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sxkcd_ServiceDesc.Streams[1], Sxkcd_WatchNewComics_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sxkcdWatchNewComicsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Sxkcd_WatchNewComicsClient interface {
+	Recv() (*Comic, error)
+	grpc.ClientStream
+}
+
+type sxkcdWatchNewComicsClient struct {
+	grpc.ClientStream
+}
+
+func (x *sxkcdWatchNewComicsClient) Recv() (*Comic, error) {
+	m := new(Comic)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SxkcdServer is the server API for Sxkcd service.
+// All implementations must embed UnimplementedSxkcdServer
+// for forward compatibility
+type SxkcdServer interface {
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	GetComic(context.Context, *GetComicRequest) (*Comic, error)
+	StreamComics(*StreamComicsRequest, Sxkcd_StreamComicsServer) error
+	WatchNewComics(*WatchNewComicsRequest, Sxkcd_WatchNewComicsServer) error
+	mustEmbedUnimplementedSxkcdServer()
+}
+
+// UnimplementedSxkcdServer must be embedded to have forward compatible implementations.
+type UnimplementedSxkcdServer struct {
+}
+
+func (UnimplementedSxkcdServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSxkcdServer) GetComic(context.Context, *GetComicRequest) (*Comic, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetComic not implemented")
+}
+func (UnimplementedSxkcdServer) StreamComics(*StreamComicsRequest, Sxkcd_StreamComicsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamComics not implemented")
+}
+func (UnimplementedSxkcdServer) WatchNewComics(*WatchNewComicsRequest, Sxkcd_WatchNewComicsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchNewComics not implemented")
+}
+func (UnimplementedSxkcdServer) mustEmbedUnimplementedSxkcdServer() {}
+
+// UnsafeSxkcdServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SxkcdServer will
+// result in compilation errors.
+type UnsafeSxkcdServer interface {
+	mustEmbedUnimplementedSxkcdServer()
+}
+
+func RegisterSxkcdServer(s grpc.ServiceRegistrar, srv SxkcdServer) {
+	s.RegisterService(&Sxkcd_ServiceDesc, srv)
+}
+
+func _Sxkcd_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SxkcdServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sxkcd_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SxkcdServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sxkcd_GetComic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetComicRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SxkcdServer).GetComic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sxkcd_GetComic_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SxkcdServer).GetComic(ctx, req.(*GetComicRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sxkcd_StreamComics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamComicsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SxkcdServer).StreamComics(m, &sxkcdStreamComicsServer{stream})
+}
+
+type Sxkcd_StreamComicsServer interface {
+	Send(*Comic) error
+	grpc.ServerStream
+}
+
+type sxkcdStreamComicsServer struct {
+	grpc.ServerStream
+}
+
+func (x *sxkcdStreamComicsServer) Send(m *Comic) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Sxkcd_WatchNewComics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchNewComicsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SxkcdServer).WatchNewComics(m, &sxkcdWatchNewComicsServer{stream})
+}
+
+type Sxkcd_WatchNewComicsServer interface {
+	Send(*Comic) error
+	grpc.ServerStream
+}
+
+type sxkcdWatchNewComicsServer struct {
+	grpc.ServerStream
+}
+
+func (x *sxkcdWatchNewComicsServer) Send(m *Comic) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Sxkcd_ServiceDesc is the grpc.ServiceDesc for Sxkcd service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sxkcd_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sxkcd.Sxkcd",
+	HandlerType: (*SxkcdServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _Sxkcd_Search_Handler,
+		},
+		{
+			MethodName: "GetComic",
+			Handler:    _Sxkcd_GetComic_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamComics",
+			Handler:       _Sxkcd_StreamComics_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchNewComics",
+			Handler:       _Sxkcd_WatchNewComics_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sxkcd.proto",
+}
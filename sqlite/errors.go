@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kencx/sxkcd/store"
+)
+
+// classify wraps errors returned by SQLite with one of the store errors so
+// callers can distinguish bad queries from backend failures with errors.Is
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", store.ErrTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return err
+	case strings.Contains(msg, "fts5:"):
+		return fmt.Errorf("%w: %w", store.ErrInvalidQuery, err)
+	default:
+		// missing tables, locked or corrupt database etc.
+		return fmt.Errorf("%w: %w", store.ErrUnavailable, err)
+	}
+}
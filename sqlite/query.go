@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"math"
+	"strings"
+
+	"github.com/kencx/sxkcd/store/query"
+)
+
+// bm25 with the column weights of the RediSearch schema. Lower is better.
+const rankExpr = "bm25(comics_fts, 50.0, 10.0, 5.0, 1.0)"
+
+// translation is a query split into an FTS5 MATCH expression, which is used
+// for ranking, and an SQL condition on the comics table c for everything that
+// cannot be expressed in FTS5, such as numeric ranges and negation-only
+// queries
+type translation struct {
+	match string
+	where string
+	args  []interface{}
+}
+
+func translate(n query.Node) translation {
+	if m, ok := fts(n); ok {
+		return translation{match: m}
+	}
+
+	var t translation
+	and, ok := n.(query.And)
+	if !ok {
+		t.where, t.args = where(n)
+		return t
+	}
+
+	var (
+		matches []string
+		conds   []string
+	)
+	for _, c := range and {
+		if m, ok := fts(c); ok {
+			matches = append(matches, "("+m+")")
+			continue
+		}
+		w, args := where(c)
+		conds = append(conds, w)
+		t.args = append(t.args, args...)
+	}
+	t.match = strings.Join(matches, " AND ")
+	t.where = strings.Join(conds, " AND ")
+	return t
+}
+
+// fts returns the FTS5 expression of n. It returns false if n contains
+// numeric ranges or negations without a positive term.
+func fts(n query.Node) (string, bool) {
+	switch n := n.(type) {
+	case query.Term:
+		s := `"` + strings.ReplaceAll(n.Text, `"`, `""`) + `"`
+		if n.Prefix {
+			s += "*"
+		}
+		if n.Field != "" {
+			s = n.Field + " : " + s
+		}
+		return s, true
+
+	case query.And:
+		var pos, neg []string
+		for _, c := range n {
+			if not, ok := c.(query.Not); ok {
+				m, ok := fts(not.Node)
+				if !ok {
+					return "", false
+				}
+				neg = append(neg, " NOT ("+m+")")
+				continue
+			}
+
+			m, ok := fts(c)
+			if !ok {
+				return "", false
+			}
+			pos = append(pos, "("+m+")")
+		}
+		if len(pos) == 0 {
+			return "", false
+		}
+		return strings.Join(pos, " AND ") + strings.Join(neg, ""), true
+
+	case query.Or:
+		parts := make([]string, len(n))
+		for i, c := range n {
+			m, ok := fts(c)
+			if !ok {
+				return "", false
+			}
+			parts[i] = "(" + m + ")"
+		}
+		return strings.Join(parts, " OR "), true
+	}
+	return "", false
+}
+
+// where returns an SQL condition on the comics table c that is equivalent
+// to n
+func where(n query.Node) (string, []interface{}) {
+	if m, ok := fts(n); ok {
+		return "c.num IN (SELECT rowid FROM comics_fts WHERE comics_fts MATCH ?)", []interface{}{m}
+	}
+
+	switch n := n.(type) {
+	case query.And:
+		return join(n, " AND ")
+	case query.Or:
+		return join(n, " OR ")
+
+	case query.Not:
+		w, args := where(n.Node)
+		return "NOT " + w, args
+
+	case query.Range:
+		col := "c.num"
+		if n.Field == "date" {
+			col = "c.date"
+		}
+
+		conds := []string{"1"}
+		var args []interface{}
+		if !math.IsInf(n.From, 0) {
+			conds = append(conds, col+" >= ?")
+			args = append(args, n.From)
+		}
+		if !math.IsInf(n.To, 0) {
+			conds = append(conds, col+" <= ?")
+			args = append(args, n.To)
+		}
+		return "(" + strings.Join(conds, " AND ") + ")", args
+	}
+
+	// query.All
+	return "1", nil
+}
+
+func join(nodes []query.Node, op string) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, n := range nodes {
+		w, a := where(n)
+		conds = append(conds, w)
+		args = append(args, a...)
+	}
+	return "(" + strings.Join(conds, op) + ")", args
+}
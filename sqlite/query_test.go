@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/store/query"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  translation
+	}{
+		{"term", "python", translation{match: `"python"`}},
+		{"prefix", "pyth*", translation{match: `"pyth"*`}},
+		{"field", "@title:git", translation{match: `title : "git"`}},
+		{"and not", "sandwich -sudo", translation{match: `("sandwich") NOT ("sudo")`}},
+		{"or", "a1|b2 c3", translation{match: `(("a1") OR ("b2")) AND ("c3")`}},
+		{"range", "@num: [1 10]", translation{
+			where: "(1 AND c.num >= ? AND c.num <= ?)",
+			args:  []interface{}{1.0, 10.0},
+		}},
+		{"negation only", "-sudo", translation{
+			where: "NOT c.num IN (SELECT rowid FROM comics_fts WHERE comics_fts MATCH ?)",
+			args:  []interface{}{`"sudo"`},
+		}},
+		{"term and range", "git @date: [0 inf]", translation{
+			match: `("git")`,
+			where: "(1 AND c.date >= ?)",
+			args:  []interface{}{0.0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := query.Parse(tt.query)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got := translate(n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
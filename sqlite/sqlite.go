@@ -0,0 +1,565 @@
+// Package sqlite implements a search backend that stores comics in a single
+// SQLite database with an FTS5 full-text index. The database is a portable
+// artifact that can also be queried directly:
+//
+//	SELECT num, title FROM comics_fts JOIN comics ON num = comics_fts.rowid
+//	WHERE comics_fts MATCH 'python' ORDER BY bm25(comics_fts, 50, 10, 5, 1);
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/store"
+	"github.com/kencx/sxkcd/store/query"
+	_ "modernc.org/sqlite"
+)
+
+var _ store.Store = (*Store)(nil)
+
+const schema = `
+CREATE TABLE comics (
+	num         INTEGER PRIMARY KEY,
+	title       TEXT NOT NULL DEFAULT '',
+	alt         TEXT NOT NULL DEFAULT '',
+	transcript  TEXT NOT NULL DEFAULT '',
+	explanation TEXT NOT NULL DEFAULT '',
+	img_url     TEXT NOT NULL DEFAULT '',
+	date        INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX comics_date ON comics (date);
+
+CREATE VIRTUAL TABLE comics_fts USING fts5(
+	title, alt, transcript, explanation,
+	content='comics', content_rowid='num'
+);
+
+CREATE TRIGGER comics_ai AFTER INSERT ON comics BEGIN
+	INSERT INTO comics_fts (rowid, title, alt, transcript, explanation)
+	VALUES (new.num, new.title, new.alt, new.transcript, new.explanation);
+END;
+CREATE TRIGGER comics_ad AFTER DELETE ON comics BEGIN
+	INSERT INTO comics_fts (comics_fts, rowid, title, alt, transcript, explanation)
+	VALUES ('delete', old.num, old.title, old.alt, old.transcript, old.explanation);
+END;
+CREATE TRIGGER comics_au AFTER UPDATE ON comics BEGIN
+	INSERT INTO comics_fts (comics_fts, rowid, title, alt, transcript, explanation)
+	VALUES ('delete', old.num, old.title, old.alt, old.transcript, old.explanation);
+	INSERT INTO comics_fts (rowid, title, alt, transcript, explanation)
+	VALUES (new.num, new.title, new.alt, new.transcript, new.explanation);
+END;
+`
+
+const (
+	columns = "c.num, c.title, c.alt, c.transcript, c.explanation, c.img_url, c.date"
+
+	upsert = `INSERT INTO comics (num, title, alt, transcript, explanation, img_url, date)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (num) DO UPDATE SET
+	title = excluded.title,
+	alt = excluded.alt,
+	transcript = excluded.transcript,
+	explanation = excluded.explanation,
+	img_url = excluded.img_url,
+	date = excluded.date`
+
+	// merge is identical to upsert but keeps existing values for empty fields
+	merge = `INSERT INTO comics (num, title, alt, transcript, explanation, img_url, date)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (num) DO UPDATE SET
+	title = coalesce(nullif(excluded.title, ''), title),
+	alt = coalesce(nullif(excluded.alt, ''), alt),
+	transcript = coalesce(nullif(excluded.transcript, ''), transcript),
+	explanation = coalesce(nullif(excluded.explanation, ''), explanation),
+	img_url = coalesce(nullif(excluded.img_url, ''), img_url),
+	date = coalesce(nullif(excluded.date, 0), date)`
+)
+
+// columns that may be patched
+var patchable = map[string]bool{
+	"title":       true,
+	"alt":         true,
+	"transcript":  true,
+	"explanation": true,
+	"img_url":     true,
+	"date":        true,
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+// New opens the SQLite database at path, creating it if it does not exist
+func New(path string) (*Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no database file provided")
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// WriteFile writes comics to a new SQLite database at path, replacing any
+// existing file. The database is built in a temporary file that is renamed to
+// path, so that the existing database is kept if writing fails.
+func WriteFile(path string, comics []data.Comic) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer func() {
+		for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+			os.Remove(tmp.Name() + suffix)
+		}
+	}()
+
+	if err := build(tmp.Name(), comics); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// the log of the replaced database does not belong to the new one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// build writes comics to the empty database at path
+func build(path string, comics []data.Comic) error {
+	s, err := New(path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := s.CreateIndex(ctx); err != nil {
+		return err
+	}
+	if err := s.AddBatch(ctx, comics); err != nil {
+		return err
+	}
+
+	// merge the write-ahead log so that the database is a single file
+	if _, err := s.db.Exec("PRAGMA journal_mode = DELETE"); err != nil {
+		return fmt.Errorf("failed to checkpoint %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadFile returns all comics of the SQLite database at path
+func ReadFile(path string) ([]data.Comic, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	s, err := New(path)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return s.All(context.Background())
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) CreateIndex(ctx context.Context) error {
+	ok, err := s.CheckIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return store.ErrIndexExists
+	}
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("failed to create index: %w", classify(err))
+	}
+	return nil
+}
+
+// Reindex recreates the tables with comics in a single transaction. Readers
+// see the existing comics until it is committed.
+func (s *Store) Reindex(ctx context.Context, comics []data.Comic) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classify(err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{"DROP TABLE IF EXISTS comics_fts", "DROP TABLE IF EXISTS comics", schema} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to reindex: %w", classify(err))
+		}
+	}
+	if err := s.insert(ctx, tx, comics); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to reindex: %w", classify(err))
+	}
+	return nil
+}
+
+func (s *Store) CheckIndex(ctx context.Context) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('comics', 'comics_fts')",
+	).Scan(&n)
+	if err != nil {
+		return false, classify(err)
+	}
+	return n == 2, nil
+}
+
+// Ping returns the round trip time of a trivial query
+func (s *Store) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if err := s.db.PingContext(ctx); err != nil {
+		return 0, classify(err)
+	}
+	return time.Since(start), nil
+}
+
+func (s *Store) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM comics").Scan(&count); err != nil {
+		return -1, classify(err)
+	}
+	return count, nil
+}
+
+// Add adds comic num, or updates the fields that changed if it already exists
+func (s *Store) Add(ctx context.Context, num int, comic []byte) error {
+	c, err := decodeComic(num, comic)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, merge, comicArgs(&c)...)
+	if err != nil {
+		return fmt.Errorf("failed to add comic: %w", classify(err))
+	}
+	return nil
+}
+
+// AddBatch adds all comics in a single transaction, replacing existing
+// comics
+func (s *Store) AddBatch(ctx context.Context, comics []data.Comic) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classify(err)
+	}
+	defer tx.Rollback()
+
+	if err := s.insert(ctx, tx, comics); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to index: %w", classify(err))
+	}
+	return nil
+}
+
+// insert upserts comics within tx
+func (s *Store) insert(ctx context.Context, tx *sql.Tx, comics []data.Comic) error {
+	stmt, err := tx.PrepareContext(ctx, upsert)
+	if err != nil {
+		return fmt.Errorf("failed to index: %w", classify(err))
+	}
+	defer stmt.Close()
+
+	for i := range comics {
+		if _, err := stmt.ExecContext(ctx, comicArgs(&comics[i])...); err != nil {
+			return fmt.Errorf("failed to index comic %d: %w", comics[i].Number, classify(err))
+		}
+	}
+	return nil
+}
+
+func (s *Store) Replace(ctx context.Context, num int, comic []byte) error {
+	c, err := decodeComic(num, comic)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, upsert, comicArgs(&c)...); err != nil {
+		return fmt.Errorf("failed to replace comic %d: %w", num, classify(err))
+	}
+	return nil
+}
+
+// Patch sets the given string fields of comic num in a single transaction
+func (s *Store) Patch(ctx context.Context, num int, fields map[string]string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classify(err)
+	}
+	defer tx.Rollback()
+
+	for field, value := range fields {
+		if !patchable[field] {
+			return fmt.Errorf("failed to patch comic %d: unknown field %q", num, field)
+		}
+
+		res, err := tx.ExecContext(ctx, "UPDATE comics SET "+field+" = ? WHERE num = ?", value, num)
+		if err != nil {
+			return fmt.Errorf("failed to patch comic %d: %w", num, classify(err))
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return store.ErrNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to patch comic %d: %w", num, classify(err))
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, num int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM comics WHERE num = ?", num)
+	if err != nil {
+		return fmt.Errorf("failed to delete comic %d: %w", num, classify(err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) ComicExists(ctx context.Context, num int) (bool, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM comics WHERE num = ?", num).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to find comic %d: %w", num, classify(err))
+	}
+	return n > 0, nil
+}
+
+func (s *Store) Get(ctx context.Context, num int) (*data.Comic, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+columns+" FROM comics c WHERE c.num = ?", num)
+
+	c, err := scanComic(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comic %d: %w", num, classify(err))
+	}
+	return c, nil
+}
+
+func (s *Store) Latest(ctx context.Context) (*data.Comic, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+columns+" FROM comics c ORDER BY c.num DESC LIMIT 1")
+
+	c, err := scanComic(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest comic: %w", classify(err))
+	}
+	return c, nil
+}
+
+func (s *Store) All(ctx context.Context) ([]data.Comic, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+columns+" FROM comics c ORDER BY c.num")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comics: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var comics []data.Comic
+	for rows.Next() {
+		c, err := scanComic(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read comics: %w", err)
+		}
+		comics = append(comics, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read comics: %w", classify(err))
+	}
+	return comics, nil
+}
+
+func (s *Store) Search(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*store.Result, error) {
+	if opts == nil {
+		opts = &store.SearchOptions{}
+	}
+
+	count, comics, err := s.search(ctx, query, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	results := make([]*store.Result, len(comics))
+	for i, c := range comics {
+		results[i] = store.NewResult(i, c, opts.Fields)
+	}
+	return count, results, nil
+}
+
+func (s *Store) SearchBatch(ctx context.Context, queries []string, opts []*store.SearchOptions) ([]store.BatchResult, error) {
+	if len(queries) != len(opts) {
+		return nil, fmt.Errorf("expected %d search options, got %d", len(queries), len(opts))
+	}
+
+	results := make([]store.BatchResult, len(queries))
+	for i, q := range queries {
+		results[i].Count, results[i].Results, results[i].Err = s.Search(ctx, q, opts[i])
+
+		// only errors specific to a query are returned per query
+		if errors.Is(results[i].Err, store.ErrUnavailable) || errors.Is(results[i].Err, store.ErrTimeout) {
+			return nil, fmt.Errorf("search batch failed: %w", results[i].Err)
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) SearchComics(ctx context.Context, query string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	if opts == nil {
+		opts = &store.SearchOptions{}
+	}
+	if len(opts.Fields) > 0 {
+		return 0, nil, fmt.Errorf("fields are not supported when searching full documents")
+	}
+	return s.search(ctx, query, opts)
+}
+
+// search returns the total number of matches and the requested page of
+// comics
+func (s *Store) search(ctx context.Context, q string, opts *store.SearchOptions) (int64, []*data.Comic, error) {
+	switch opts.Mode {
+	case "", store.ModeKeyword:
+	case store.ModeSemantic, store.ModeHybrid:
+		return 0, nil, fmt.Errorf("search query failed: %w: %s search is not supported by the sqlite backend", store.ErrInvalidQuery, opts.Mode)
+	default:
+		return 0, nil, fmt.Errorf("search query failed: %w: unknown search mode %q", store.ErrInvalidQuery, opts.Mode)
+	}
+
+	n, err := query.Parse(q)
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w: %w", store.ErrInvalidQuery, err)
+	}
+	if n == nil {
+		return 0, nil, nil
+	}
+
+	var order string
+	switch opts.SortBy {
+	case "":
+		order = "score, c.num DESC"
+	case "num", "date":
+		order = "c." + opts.SortBy + " DESC"
+		if opts.Ascending {
+			order = "c." + opts.SortBy + " ASC"
+		}
+	default:
+		return 0, nil, fmt.Errorf("search query failed: %w: unknown sort field %q", store.ErrInvalidQuery, opts.SortBy)
+	}
+
+	t := translate(n)
+	from, rank := "comics c", "0"
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if t.match != "" {
+		from = "comics_fts JOIN comics c ON c.num = comics_fts.rowid"
+		rank = rankExpr
+		conds = append(conds, "comics_fts MATCH ?")
+		args = append(args, t.match)
+	}
+	if t.where != "" {
+		conds = append(conds, t.where)
+		args = append(args, t.args...)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var count int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+from+where, args...).Scan(&count); err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+
+	limit := store.PageSize(opts)
+	if limit == 0 {
+		return count, nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+columns+", "+rank+" AS score FROM "+from+where+
+			" ORDER BY "+order+" LIMIT ? OFFSET ?",
+		append(args, limit, max(opts.Offset, 0))...,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var comics []*data.Comic
+	for rows.Next() {
+		var (
+			c     data.Comic
+			score float64
+		)
+		if err := rows.Scan(&c.Number, &c.Title, &c.Alt, &c.Transcript, &c.Explanation, &c.ImgUrl, &c.Date, &score); err != nil {
+			return 0, nil, fmt.Errorf("search result could not be parsed: %w", err)
+		}
+		comics = append(comics, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("search query failed: %w", classify(err))
+	}
+	return count, comics, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanComic(row scanner) (*data.Comic, error) {
+	var c data.Comic
+	if err := row.Scan(&c.Number, &c.Title, &c.Alt, &c.Transcript, &c.Explanation, &c.ImgUrl, &c.Date); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func comicArgs(c *data.Comic) []interface{} {
+	return []interface{}{c.Number, c.Title, c.Alt, c.Transcript, c.Explanation, c.ImgUrl, c.Date}
+}
+
+func decodeComic(num int, b []byte) (data.Comic, error) {
+	var c data.Comic
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("failed to unmarshal comic %d: %w", num, err)
+	}
+	c.Number = num
+	return c, nil
+}
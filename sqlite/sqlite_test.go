@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/store"
+	"github.com/kencx/sxkcd/store/storetest"
+)
+
+func newTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := New(filepath.Join(t.TempDir(), "sxkcd.db"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, newTestStore)
+}
+
+func TestSearchModes(t *testing.T) {
+	ctx := context.Background()
+
+	s := newTestStore(t)
+	storetest.Populate(t, s)
+
+	if _, _, err := s.Search(ctx, "python", &store.SearchOptions{Mode: store.ModeSemantic}); !errors.Is(err, store.ErrInvalidQuery) {
+		t.Errorf("got %v, want %v", err, store.ErrInvalidQuery)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "comics.db")
+
+	if err := WriteFile(path, storetest.Comics); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// existing files are replaced
+	if err := WriteFile(path, storetest.Comics[:2]); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// temporary files are renamed or removed
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("got %d files, want only the database", len(entries))
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateIndex(ctx); !errors.Is(err, store.ErrIndexExists) {
+		t.Errorf("got %v, want %v", err, store.ErrIndexExists)
+	}
+	if count, _ := s.Count(ctx); count != 2 {
+		t.Errorf("got %d, want %d", count, 2)
+	}
+	if _, results, _ := s.Search(ctx, "sandwich", nil); !reflect.DeepEqual(storetest.Nums(results), []int{149}) {
+		t.Errorf("got %v, want %v", storetest.Nums(results), []int{149})
+	}
+}
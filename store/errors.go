@@ -0,0 +1,11 @@
+package store
+
+import "errors"
+
+var (
+	ErrNotFound     = errors.New("comic not found")
+	ErrInvalidQuery = errors.New("invalid query")
+	ErrUnavailable  = errors.New("backend unavailable")
+	ErrTimeout      = errors.New("backend timed out")
+	ErrIndexExists  = errors.New("index already exists")
+)
@@ -0,0 +1,322 @@
+// Package query parses the subset of the RediSearch query syntax produced by
+// the http package, so that backends other than Redis can evaluate it:
+//
+//	foo bar       documents containing foo and bar
+//	foo|bar       documents containing foo or bar, | binds tighter than AND
+//	-foo          documents not containing foo
+//	foo*          prefix search
+//	@title:foo    search a single text field
+//	@num: [1 10]  numeric range of num or date, inclusive
+//	*             all documents
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// text fields that can be searched, in the order of the RediSearch schema
+var TextFields = []string{"title", "alt", "transcript", "explanation"}
+
+// default RediSearch stop words, which are neither indexed nor searched
+var stopWords = map[string]bool{
+	"a": true, "is": true, "the": true, "an": true, "and": true, "are": true,
+	"as": true, "at": true, "be": true, "but": true, "by": true, "for": true,
+	"if": true, "in": true, "into": true, "it": true, "no": true, "not": true,
+	"of": true, "on": true, "or": true, "such": true, "that": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "was": true, "will": true, "with": true,
+}
+
+// Node is a parsed query: And, Or, Not, All, Term or Range
+type Node interface {
+	node()
+}
+
+// And matches documents matched by all nodes
+type And []Node
+
+// Or matches documents matched by any node
+type Or []Node
+
+// Not matches all documents not matched by Node
+type Not struct {
+	Node Node
+}
+
+// All matches all documents
+type All struct{}
+
+// Term matches documents containing Text in Field, or in any text field if
+// Field is empty. If Prefix is true, any term starting with Text matches.
+type Term struct {
+	Text   string
+	Field  string
+	Prefix bool
+}
+
+// Range matches documents whose numeric Field (num or date) is within
+// [From, To]
+type Range struct {
+	Field    string
+	From, To float64
+}
+
+func (And) node()   {}
+func (Or) node()    {}
+func (Not) node()   {}
+func (All) node()   {}
+func (Term) node()  {}
+func (Range) node() {}
+
+// Tokenize lowercases s and splits it into terms on all characters that are
+// not letters or digits. Stop words are removed.
+func Tokenize(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := words[:0]
+	for _, w := range words {
+		if !stopWords[w] {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// Parse parses query. A nil Node matches no documents, e.g. if the query only
+// contains stop words.
+func Parse(query string) (Node, error) {
+	p := &parser{s: []rune(query)}
+
+	var and And
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			and = append(and, n)
+		}
+	}
+	return simplify(and), nil
+}
+
+// Semantic splits n into the text of its terms, which is matched by meaning in
+// semantic search, and the numeric ranges that must match. Negated terms and
+// ranges within an Or are ignored.
+func Semantic(n Node) (string, []Range) {
+	var (
+		terms  []string
+		ranges []Range
+	)
+
+	var walk func(n Node, top bool)
+	walk = func(n Node, top bool) {
+		switch n := n.(type) {
+		case And:
+			for _, c := range n {
+				walk(c, top)
+			}
+		case Or:
+			for _, c := range n {
+				walk(c, false)
+			}
+		case Term:
+			terms = append(terms, n.Text)
+		case Range:
+			if top {
+				ranges = append(ranges, n)
+			}
+		}
+	}
+	walk(n, true)
+	return strings.Join(terms, " "), ranges
+}
+
+// simplify unwraps And and Or nodes with fewer than two children
+func simplify(n Node) Node {
+	var nodes []Node
+	switch n := n.(type) {
+	case And:
+		nodes = n
+	case Or:
+		nodes = n
+	default:
+		return n
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	default:
+		return n
+	}
+}
+
+type parser struct {
+	s   []rune
+	pos int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *parser) readWhile(f func(rune) bool) string {
+	start := p.pos
+	for !p.eof() && f(p.peek()) {
+		p.pos++
+	}
+	return string(p.s[start:p.pos])
+}
+
+func (p *parser) parseOr() (Node, error) {
+	var or Or
+	for {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			or = append(or, n)
+		}
+
+		p.skipSpace()
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+		p.skipSpace()
+		if p.eof() {
+			return nil, fmt.Errorf("syntax error at offset %d: expected term after |", p.pos)
+		}
+	}
+	return simplify(or), nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	p.skipSpace()
+	if p.peek() == '-' {
+		p.pos++
+		n, err := p.parseUnary()
+		if err != nil || n == nil {
+			return nil, err
+		}
+		return Not{n}, nil
+	}
+
+	if p.peek() == '@' {
+		return p.parseField()
+	}
+	return p.parseWord("")
+}
+
+// parseField parses @field:term or @field: [from to]
+func (p *parser) parseField() (Node, error) {
+	start := p.pos
+	p.pos++
+
+	name := p.readWhile(func(r rune) bool { return unicode.IsLetter(r) || r == '_' })
+	if p.peek() != ':' {
+		return nil, fmt.Errorf("syntax error at offset %d: expected : after @%s", p.pos, name)
+	}
+	p.pos++
+	p.skipSpace()
+
+	if name == "num" || name == "date" {
+		if p.peek() != '[' {
+			return nil, fmt.Errorf("syntax error at offset %d: expected numeric range", p.pos)
+		}
+		p.pos++
+		r := p.readWhile(func(r rune) bool { return r != ']' })
+		if p.eof() {
+			return nil, fmt.Errorf("syntax error at offset %d: expected ]", p.pos)
+		}
+		p.pos++
+
+		bounds := strings.Fields(r)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("syntax error at offset %d: expected [from to]", start)
+		}
+		from, err := parseBound(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseBound(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		return Range{Field: name, From: from, To: to}, nil
+	}
+
+	for _, f := range TextFields {
+		if f == name {
+			return p.parseWord(f)
+		}
+	}
+	return nil, fmt.Errorf("unknown field %q at offset %d", name, start)
+}
+
+func parseBound(s string) (float64, error) {
+	switch s {
+	case "-inf":
+		return math.Inf(-1), nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid numeric bound %q", s)
+	}
+	return v, nil
+}
+
+// parseWord parses a term or prefix in field, or all fields if field is
+// empty. Words that contain several tokens, such as "e-mail", match
+// documents containing all tokens.
+func (p *parser) parseWord(field string) (Node, error) {
+	w := p.readWhile(func(r rune) bool { return !unicode.IsSpace(r) && r != '|' })
+	if w == "" {
+		return nil, fmt.Errorf("syntax error at offset %d: expected term", p.pos)
+	}
+	if w == "*" && field == "" {
+		return All{}, nil
+	}
+
+	prefix := strings.HasSuffix(w, "*")
+	tokens := Tokenize(strings.TrimRight(w, "*"))
+
+	var and And
+	for i, t := range tokens {
+		and = append(and, Term{
+			Text:   t,
+			Field:  field,
+			Prefix: prefix && i == len(tokens)-1,
+		})
+	}
+	return simplify(and), nil
+}
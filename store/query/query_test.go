@@ -0,0 +1,77 @@
+package query
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  Node
+	}{
+		{"term", "Python", Term{Text: "python"}},
+		{"and", "foo bar", And{Term{Text: "foo"}, Term{Text: "bar"}}},
+		{"or binds tighter", "foo bar|baz", And{Term{Text: "foo"}, Or{Term{Text: "bar"}, Term{Text: "baz"}}}},
+		{"not", "-foo", Not{Term{Text: "foo"}}},
+		{"prefix", "foo*", Term{Text: "foo", Prefix: true}},
+		{"field", "@alt:foo", Term{Text: "foo", Field: "alt"}},
+		{"multiple tokens", "e-mail", And{Term{Text: "e"}, Term{Text: "mail"}}},
+		{"range", "@num: [1 10]", Range{Field: "num", From: 1, To: 10}},
+		{"open range", "@date: [-inf 10]", Range{Field: "date", From: math.Inf(-1), To: 10}},
+		{"all", "*", All{}},
+		{"stop words", "the of", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	for _, q := range []string{"@foo:bar", "@num: [1]", "@num: [a b]", "@num:1", "foo|"} {
+		if _, err := Parse(q); err == nil {
+			t.Errorf("%q: expected err", q)
+		}
+	}
+}
+
+func TestSemantic(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		text   string
+		ranges []Range
+	}{
+		{"terms", "fear of commitment", "fear commitment", nil},
+		{"not", "marriage -wedding", "marriage", nil},
+		{"or", "cats|dogs", "cats dogs", nil},
+		{"range", "space @date: [1 10]", "space", []Range{{Field: "date", From: 1, To: 10}}},
+		{"range in or", "space|@num: [1 10]", "space", nil},
+		{"all", "*", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			text, ranges := Semantic(n)
+			if text != tt.text {
+				t.Errorf("got %q, want %q", text, tt.text)
+			}
+			if !reflect.DeepEqual(ranges, tt.ranges) {
+				t.Errorf("got %v, want %v", ranges, tt.ranges)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+// Package store defines the storage and search backend used by the server
+// and worker. Queries use the RediSearch syntax produced by the http package:
+// terms, prefixes (term*), negation (-term), OR (|) and the numeric filters
+// @num: [from to] and @date: [from to].
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kencx/sxkcd/data"
+)
+
+// DefaultLimit is the number of results returned when SearchOptions.Limit is 0
+const DefaultLimit = 100
+
+// Result is identical to data.Comic but excludes the unnecessary
+// transcript and explain attributes that are not rendered but
+// usually very large. They are only populated when explicitly
+// requested with SearchOptions.Fields.
+type Result struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	Number      int    `json:"num"`
+	Alt         string `json:"alt,omitempty"`
+	ImgUrl      string `json:"img_url"`
+	Date        int64  `json:"date"`
+	Transcript  string `json:"transcript,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+
+	// selected JSON fields, or nil for the default fields
+	fields []string
+}
+
+// MarshalJSON encodes only id, num and the selected fields of a result of
+// SearchOptions.Fields, even if they are empty. Other results always include
+// title, img_url and date.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	if len(r.fields) == 0 {
+		return json.Marshal(result(r))
+	}
+
+	m := map[string]interface{}{"id": r.Id, "num": r.Number}
+	for _, f := range r.fields {
+		switch f {
+		case "title":
+			m[f] = r.Title
+		case "alt":
+			m[f] = r.Alt
+		case "img_url":
+			m[f] = r.ImgUrl
+		case "date":
+			m[f] = r.Date
+		case "transcript":
+			m[f] = r.Transcript
+		case "explanation":
+			m[f] = r.Explanation
+		}
+	}
+	return json.Marshal(m)
+}
+
+// NewResult returns the fields of c that are shown in search results, or only
+// num and fields if fields is not empty
+func NewResult(id int, c *data.Comic, fields []string) *Result {
+	if len(fields) == 0 {
+		return &Result{
+			Id:     id,
+			Title:  c.Title,
+			Number: c.Number,
+			Alt:    c.Alt,
+			ImgUrl: c.ImgUrl,
+			Date:   c.Date,
+		}
+	}
+
+	res := &Result{Id: id, Number: c.Number, fields: fields}
+	for _, f := range fields {
+		switch f {
+		case "title":
+			res.Title = c.Title
+		case "alt":
+			res.Alt = c.Alt
+		case "img_url":
+			res.ImgUrl = c.ImgUrl
+		case "date":
+			res.Date = c.Date
+		case "transcript":
+			res.Transcript = c.Transcript
+		case "explanation":
+			res.Explanation = c.Explanation
+		}
+	}
+	return res
+}
+
+// Changes returns the fields of c that differ from old, keyed by JSON name as
+// accepted by Store.Patch. The date is formatted as decimal unix time. Empty
+// fields of c are ignored so that a partially fetched comic does not erase
+// existing values.
+func Changes(old, c *data.Comic) map[string]string {
+	fields := make(map[string]string)
+	set := func(name, prev, next string) {
+		if next != "" && next != prev {
+			fields[name] = next
+		}
+	}
+	set("title", old.Title, c.Title)
+	set("alt", old.Alt, c.Alt)
+	set("transcript", old.Transcript, c.Transcript)
+	set("explanation", old.Explanation, c.Explanation)
+	set("img_url", old.ImgUrl, c.ImgUrl)
+	if c.Date != 0 && c.Date != old.Date {
+		fields["date"] = strconv.FormatInt(c.Date, 10)
+	}
+	return fields
+}
+
+// Apply sets the given string fields of c, keyed by JSON name
+func Apply(c *data.Comic, fields map[string]string) error {
+	for field, value := range fields {
+		switch field {
+		case "date":
+			date, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid date %q", value)
+			}
+			c.Date = date
+		case "title":
+			c.Title = value
+		case "alt":
+			c.Alt = value
+		case "transcript":
+			c.Transcript = value
+		case "explanation":
+			c.Explanation = value
+		case "img_url":
+			c.ImgUrl = value
+		default:
+			return fmt.Errorf("unknown field %q", field)
+		}
+	}
+	return nil
+}
+
+// MergeResult counts the comics of a Merge by outcome
+type MergeResult struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Merge adds the comics that are not in s and updates the comics whose
+// content hash differs, without replacing the other comics of s. Empty fields
+// do not overwrite existing values, and comics that only differ in empty
+// fields are unchanged.
+func Merge(ctx context.Context, s Store, comics []data.Comic) (MergeResult, error) {
+	var result MergeResult
+
+	existing, err := s.All(ctx)
+	if err != nil {
+		return result, err
+	}
+	stored := make(map[int]*data.Comic, len(existing))
+	for i := range existing {
+		stored[existing[i].Number] = &existing[i]
+	}
+
+	var (
+		added []data.Comic
+		// duplicates of new comics replace the previous one
+		addedIndex = make(map[int]int)
+	)
+	for i := range comics {
+		c := &comics[i]
+		old, ok := stored[c.Number]
+		switch {
+		case !ok:
+			if j, dup := addedIndex[c.Number]; dup {
+				added[j] = *c
+				continue
+			}
+			addedIndex[c.Number] = len(added)
+			added = append(added, *c)
+			continue
+		case old.Hash() == c.Hash():
+			result.Unchanged++
+			continue
+		}
+
+		merged := *old
+		if err := Apply(&merged, Changes(old, c)); err != nil {
+			return result, err
+		}
+		if merged.Hash() == old.Hash() {
+			result.Unchanged++
+			continue
+		}
+
+		b, err := json.Marshal(&merged)
+		if err != nil {
+			return result, fmt.Errorf("failed to marshal comic %d: %w", c.Number, err)
+		}
+		if err := s.Replace(ctx, c.Number, b); err != nil {
+			return result, err
+		}
+		result.Updated++
+	}
+
+	if len(added) > 0 {
+		if err := s.AddBatch(ctx, added); err != nil {
+			return result, err
+		}
+	}
+	result.Added = len(added)
+	return result, nil
+}
+
+// BatchResult is the outcome of a single query in SearchBatch
+type BatchResult struct {
+	Count   int64
+	Results []*Result
+	Err     error
+}
+
+// SearchOptions controls pagination, ordering and projection of search
+// results. A zero Limit returns DefaultLimit results. Results are ordered by
+// relevance unless SortBy is set. If Fields is not empty, only the given JSON
+// fields and num are returned. Mode selects keyword, semantic or hybrid search,
+// and is keyword search if empty.
+type SearchOptions struct {
+	Offset    int
+	Limit     int
+	SortBy    string
+	Ascending bool
+	Fields    []string
+	Mode      string
+	// CountOnly returns only the number of results, without any results
+	CountOnly bool
+}
+
+// PageSize returns the number of results of a page of opts
+func PageSize(opts *SearchOptions) int {
+	switch {
+	case opts.CountOnly:
+		return 0
+	case opts.Limit <= 0:
+		return DefaultLimit
+	}
+	return opts.Limit
+}
+
+// search modes
+const (
+	// ModeKeyword ranks comics that match the query with BM25
+	ModeKeyword = "keyword"
+	// ModeSemantic ranks comics by the similarity of their embedding to the
+	// embedding of the query terms. Only numeric ranges of the query filter
+	// the results.
+	ModeSemantic = "semantic"
+	// ModeHybrid blends the scores of keyword and semantic search
+	ModeHybrid = "hybrid"
+)
+
+// HybridWeight is the weight of the semantic score in hybrid search
+const HybridWeight = 0.5
+
+// MaxCandidates is the maximum number of nearest neighbours retrieved in
+// semantic and hybrid search, which limits the offset of their results
+const MaxCandidates = 1000
+
+// Candidates returns the number of nearest neighbours to retrieve for a page
+// of semantic or hybrid search results
+func Candidates(opts *SearchOptions) int {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return min(max(opts.Offset, 0)+limit, MaxCandidates)
+}
+
+// Blend returns the hybrid scores of comics from their keyword scores,
+// normalized by the highest keyword score, and their semantic cosine
+// similarities. Comics missing from either map score 0 in it.
+func Blend(keyword, semantic map[int]float64) map[int]float64 {
+	var top float64
+	for _, s := range keyword {
+		top = max(top, s)
+	}
+
+	scores := make(map[int]float64, len(keyword)+len(semantic))
+	for num, s := range keyword {
+		if top > 0 {
+			scores[num] = (1 - HybridWeight) * s / top
+		} else {
+			scores[num] = 0
+		}
+	}
+	for num, s := range semantic {
+		scores[num] += HybridWeight * s
+	}
+	return scores
+}
+
+// Rank returns the comic numbers of scores ordered by score, then by number
+// with the newest first
+func Rank(scores map[int]float64) []int {
+	nums := make([]int, 0, len(scores))
+	for num := range scores {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		a, b := nums[i], nums[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return a > b
+	})
+	return nums
+}
+
+// Store stores comics and searches them. Every operation takes the context
+// of its caller, such as an HTTP request, and is cancelled with it. Errors are
+// wrapped with the errors of this package so callers can distinguish bad
+// queries from backend failures.
+type Store interface {
+	// CreateIndex creates the search index. It returns ErrIndexExists if the
+	// index already exists.
+	CreateIndex(ctx context.Context) error
+	// Reindex replaces all comics with comics. Searches are served from the
+	// existing comics until the new ones are completely indexed.
+	Reindex(ctx context.Context, comics []data.Comic) error
+	// CheckIndex reports whether the search index exists
+	CheckIndex(ctx context.Context) (bool, error)
+	// Ping returns the round trip time to the backend
+	Ping(ctx context.Context) (time.Duration, error)
+	// Count returns the number of indexed comics
+	Count(ctx context.Context) (int, error)
+
+	// Add adds comic num, or updates the fields that changed if it already
+	// exists. Empty fields do not overwrite existing values.
+	Add(ctx context.Context, num int, comic []byte) error
+	// AddBatch adds all comics, replacing existing documents
+	AddBatch(ctx context.Context, comics []data.Comic) error
+	// Replace overwrites comic num, or adds it if it does not exist
+	Replace(ctx context.Context, num int, comic []byte) error
+	// Patch sets the given string fields of comic num
+	Patch(ctx context.Context, num int, fields map[string]string) error
+	// Delete removes comic num
+	Delete(ctx context.Context, num int) error
+
+	ComicExists(ctx context.Context, num int) (bool, error)
+	// Get returns the full document of comic num or ErrNotFound
+	Get(ctx context.Context, num int) (*data.Comic, error)
+	// Latest returns the full document of the comic with the highest number
+	Latest(ctx context.Context) (*data.Comic, error)
+	// All returns the full documents of all comics ordered by number
+	All(ctx context.Context) ([]data.Comic, error)
+
+	Search(ctx context.Context, query string, opts *SearchOptions) (int64, []*Result, error)
+	// SearchBatch runs all queries. A failed query does not fail the batch,
+	// its error is returned in the corresponding BatchResult.
+	SearchBatch(ctx context.Context, queries []string, opts []*SearchOptions) ([]BatchResult, error)
+	// SearchComics is identical to Search but returns the full documents,
+	// including transcript and explanation
+	SearchComics(ctx context.Context, query string, opts *SearchOptions) (int64, []*data.Comic, error)
+}
+
+// Analytics is implemented by stores that can record search analytics
+type Analytics interface {
+	// RecordQuery records a normalized search query, its number of results
+	// and latency. Records are kept for retention.
+	RecordQuery(ctx context.Context, query string, results int64, latency, retention time.Duration) error
+	// TopQueries returns the most frequent queries of the last n hours. If
+	// zeroResults is true, only queries without results are counted.
+	TopQueries(ctx context.Context, hours, limit int, zeroResults bool) ([]QueryCount, error)
+	// QueriesPerHour returns the search statistics of each of the last n
+	// hours, oldest first
+	QueriesPerHour(ctx context.Context, hours int) ([]HourStats, error)
+}
+
+// SchemaMigrator is implemented by stores whose index schema may change
+// between versions
+type SchemaMigrator interface {
+	// MigrateSchema compares the schema of the existing index with the
+	// current schema and migrates the index if they differ. It returns an
+	// error if the index cannot be migrated.
+	MigrateSchema(ctx context.Context) error
+}
+
+// ConfigChecker is implemented by stores that depend on the configuration of
+// their backend
+type ConfigChecker interface {
+	// CheckConfig returns an error if the backend is configured in a way that
+	// the store cannot serve correct results with
+	CheckConfig(ctx context.Context) error
+}
+
+// QueryCount is the number of times a query was searched
+type QueryCount struct {
+	Query string `json:"query"`
+	Count int64  `json:"count"`
+}
+
+// HourStats are the aggregated search statistics of a single hour
+type HourStats struct {
+	Hour        time.Time `json:"hour"`
+	Queries     int64     `json:"queries"`
+	ZeroResults int64     `json:"zero_results"`
+	// mean latency in seconds
+	AvgLatency float64 `json:"avg_latency"`
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/data"
+)
+
+func TestChanges(t *testing.T) {
+	old := &data.Comic{Number: 1, Title: "Barrel", Alt: "Don't we all.", Explanation: "a boy in a barrel"}
+
+	tests := []struct {
+		name  string
+		comic data.Comic
+		want  map[string]string
+	}{
+		{"unchanged", *old, map[string]string{}},
+		{"changed", data.Comic{Title: "Barrel - Part 1", Alt: old.Alt}, map[string]string{"title": "Barrel - Part 1"}},
+		{"new field", data.Comic{Transcript: "a boy sits in a barrel"}, map[string]string{"transcript": "a boy sits in a barrel"}},
+		{"empty fields ignored", data.Comic{Number: 1}, map[string]string{}},
+		{"date", data.Comic{Date: 1136073600}, map[string]string{"date": "1136073600"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Changes(old, &tt.comic)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mapStore is a Store of comics by number. Methods that are not used by Merge
+// are not implemented.
+type mapStore struct {
+	Store
+	comics map[int]data.Comic
+}
+
+func (m *mapStore) All(ctx context.Context) ([]data.Comic, error) {
+	var comics []data.Comic
+	for _, c := range m.comics {
+		comics = append(comics, c)
+	}
+	return comics, nil
+}
+
+func (m *mapStore) Replace(ctx context.Context, num int, comic []byte) error {
+	var c data.Comic
+	if err := json.Unmarshal(comic, &c); err != nil {
+		return err
+	}
+	m.comics[num] = c
+	return nil
+}
+
+func (m *mapStore) AddBatch(ctx context.Context, comics []data.Comic) error {
+	for _, c := range comics {
+		m.comics[c.Number] = c
+	}
+	return nil
+}
+
+func TestMerge(t *testing.T) {
+	s := &mapStore{comics: map[int]data.Comic{
+		1: {Number: 1, Title: "Barrel", Explanation: "a boy in a barrel"},
+		2: {Number: 2, Title: "Petit Trees"},
+		// fetched by the worker
+		3: {Number: 3, Title: "Island"},
+		5: {Number: 5, Title: "Blown apart", Date: 100},
+	}}
+
+	got, err := Merge(context.Background(), s, []data.Comic{
+		{Number: 1, Title: "Barrel"},
+		{Number: 2, Title: "Petit Trees (sketch)"},
+		{Number: 4, Title: "Landscape"},
+		{Number: 4, Title: "Landscape (sketch)"},
+		{Number: 5, Title: "Blown apart", Date: 200},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	want := MergeResult{Added: 1, Updated: 2, Unchanged: 1}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if c := s.comics[1]; c.Explanation != "a boy in a barrel" {
+		t.Errorf("got explanation %q, want existing value kept", c.Explanation)
+	}
+	if c := s.comics[2]; c.Title != "Petit Trees (sketch)" {
+		t.Errorf("got title %q, want updated", c.Title)
+	}
+	if c := s.comics[5]; c.Date != 200 {
+		t.Errorf("got date %d, want corrected", c.Date)
+	}
+	if _, ok := s.comics[3]; !ok {
+		t.Errorf("expected comic 3 to be kept")
+	}
+	if c := s.comics[4]; c.Title != "Landscape (sketch)" {
+		t.Errorf("got title %q, want last duplicate", c.Title)
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	c := &data.Comic{Number: 1, Title: "Barrel - Part 1", Transcript: "a boy sits in a barrel"}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		// the default fields are always present
+		{"default", nil, `{"id":0,"title":"Barrel - Part 1","num":1,"img_url":"","date":0}`},
+		{"selected", []string{"transcript", "date"}, `{"date":0,"id":0,"num":1,"transcript":"a boy sits in a barrel"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal([]*Result{NewResult(0, c, tt.fields)})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got, want := string(b), "["+tt.want+"]"; got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
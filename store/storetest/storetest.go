@@ -0,0 +1,243 @@
+// Package storetest tests the behaviour that all implementations of
+// store.Store share.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kencx/sxkcd/data"
+	"github.com/kencx/sxkcd/store"
+)
+
+// Comics are the comics that Populate adds to a store
+var Comics = []data.Comic{
+	{Number: 1, Title: "Barrel - Part 1", Alt: "Don't we all.", Date: 1136073600},
+	{Number: 149, Title: "Sandwich", Alt: "Proper User Policy apparently means Simon Says.", Transcript: "sudo make me a sandwich", Date: 1159660800},
+	{Number: 327, Title: "Exploits of a Mom", Alt: "Her daughter is named Help I'm trapped in a driver's license factory.", Explanation: "sql injection and sanitizing database inputs", Date: 1192406400},
+	{Number: 353, Title: "Python", Alt: "I wrote 20 short programs in Python yesterday.", Transcript: "import antigravity", Date: 1196294400},
+	{Number: 1597, Title: "Git", Alt: "If that doesn't fix it, git.txt contains the phone number of a friend of mine who understands git.", Explanation: "sandwich of commands", Date: 1446163200},
+}
+
+// Populate creates the index of s and adds Comics
+func Populate(t *testing.T, s store.Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := s.CreateIndex(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := s.AddBatch(ctx, Comics); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+// Nums returns the comic numbers of results
+func Nums(results []*store.Result) []int {
+	n := make([]int, len(results))
+	for i, r := range results {
+		n[i] = r.Number
+	}
+	return n
+}
+
+// Run runs the shared tests against the stores returned by newStore, which
+// must be empty and without an index.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	populated := func(t *testing.T) store.Store {
+		s := newStore(t)
+		Populate(t, s)
+		return s
+	}
+
+	t.Run("Search", func(t *testing.T) { testSearch(t, populated(t)) })
+	t.Run("SearchRanking", func(t *testing.T) { testSearchRanking(t, populated(t)) })
+	t.Run("SearchOptions", func(t *testing.T) { testSearchOptions(t, populated(t)) })
+	t.Run("SearchErrors", func(t *testing.T) { testSearchErrors(t, populated(t), newStore(t)) })
+	t.Run("Modify", func(t *testing.T) { testModify(t, populated(t)) })
+	t.Run("Reindex", func(t *testing.T) { testReindex(t, populated(t)) })
+	t.Run("Add", func(t *testing.T) { testAdd(t, populated(t)) })
+}
+
+func testSearch(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"term", "python", []int{353}},
+		{"case insensitive", "PYTHON", []int{353}},
+		{"and", "sandwich sudo", []int{149}},
+		{"or", "python|git", []int{353, 1597}},
+		{"or binds tighter than and", "sandwich commands|sudo", []int{149, 1597}},
+		{"negation", "sandwich -sudo", []int{1597}},
+		{"negation only", "-sandwich -python -git", []int{327, 1}},
+		{"prefix", "pyth*", []int{353}},
+		{"field", "@title:sandwich", []int{149}},
+		{"num range", "@num: [300 400]", []int{353, 327}},
+		{"date range", "@date: [1159660800 1192406400]", []int{327, 149}},
+		{"all", "*", []int{1597, 353, 327, 149, 1}},
+		{"stop words", "the", []int{}},
+		{"no match", "foobar", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, results, err := s.Search(ctx, tt.query, nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Errorf("got count %d, want %d", count, len(tt.want))
+			}
+			if got := Nums(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testSearchRanking(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	// a match in the title is weighted higher than one in the explanation
+	_, results, err := s.Search(ctx, "sandwich", nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got, want := Nums(results), []int{149, 1597}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if results[0].Transcript != "" {
+		t.Errorf("expected transcript to be excluded")
+	}
+}
+
+func testSearchOptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	count, results, err := s.Search(ctx, "*", &store.SearchOptions{
+		Offset:    1,
+		Limit:     2,
+		SortBy:    "num",
+		Ascending: true,
+		Fields:    []string{"transcript"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("got count %d, want %d", count, 5)
+	}
+	if got, want := Nums(results), []int{149, 327}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if results[0].Title != "" || results[0].Transcript != "sudo make me a sandwich" {
+		t.Errorf("got %+v", results[0])
+	}
+
+	count, results, err = s.Search(ctx, "*", &store.SearchOptions{Offset: 1, CountOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if count != 5 || len(results) != 0 {
+		t.Errorf("got count %d and %d results, want count 5 only", count, len(results))
+	}
+}
+
+func testSearchErrors(t *testing.T, s, empty store.Store) {
+	ctx := context.Background()
+
+	if _, _, err := s.Search(ctx, "@foo:bar", nil); !errors.Is(err, store.ErrInvalidQuery) {
+		t.Errorf("got %v, want %v", err, store.ErrInvalidQuery)
+	}
+	if _, _, err := s.Search(ctx, "@num: [1]", nil); !errors.Is(err, store.ErrInvalidQuery) {
+		t.Errorf("got %v, want %v", err, store.ErrInvalidQuery)
+	}
+
+	expired, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	if _, _, err := s.Search(expired, "python", nil); !errors.Is(err, store.ErrTimeout) {
+		t.Errorf("got %v, want %v", err, store.ErrTimeout)
+	}
+
+	if _, _, err := empty.Search(ctx, "python", nil); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("got %v, want %v", err, store.ErrUnavailable)
+	}
+}
+
+func testModify(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if err := s.Patch(ctx, 353, map[string]string{"explanation": "flying with antigravity"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, results, _ := s.Search(ctx, "flying", nil); !reflect.DeepEqual(Nums(results), []int{353}) {
+		t.Errorf("got %v, want %v", Nums(results), []int{353})
+	}
+
+	if err := s.Delete(ctx, 353); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := s.Get(ctx, 353); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("got %v, want %v", err, store.ErrNotFound)
+	}
+	if _, results, _ := s.Search(ctx, "python|flying", nil); len(results) != 0 {
+		t.Errorf("got %v, want no results", Nums(results))
+	}
+
+	latest, err := s.Latest(ctx)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if latest.Number != 1597 {
+		t.Errorf("got %d, want %d", latest.Number, 1597)
+	}
+}
+
+func testReindex(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if err := s.Reindex(ctx, Comics[3:]); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if count, _ := s.Count(ctx); count != 2 {
+		t.Errorf("got %d, want %d", count, 2)
+	}
+	if _, err := s.Get(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("got %v, want %v", err, store.ErrNotFound)
+	}
+	if _, results, _ := s.Search(ctx, "python", nil); !reflect.DeepEqual(Nums(results), []int{353}) {
+		t.Errorf("got %v, want %v", Nums(results), []int{353})
+	}
+}
+
+func testAdd(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if err := s.Add(ctx, 2, []byte(`{"title": "Petit Trees (sketch)", "num": 2, "img_url": "x"}`)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// empty fields do not overwrite existing values
+	if err := s.Add(ctx, 353, []byte(`{"title": "Python", "num": 353, "explanation": "flying with antigravity"}`)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if count, _ := s.Count(ctx); count != len(Comics)+1 {
+		t.Errorf("got %d, want %d", count, len(Comics)+1)
+	}
+	c, err := s.Get(ctx, 353)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if c.Explanation != "flying with antigravity" || c.Transcript != "import antigravity" {
+		t.Errorf("got %+v", c)
+	}
+	if _, results, _ := s.Search(ctx, "flying", nil); !reflect.DeepEqual(Nums(results), []int{353}) {
+		t.Errorf("got %v, want %v", Nums(results), []int{353})
+	}
+}
@@ -3,7 +3,7 @@ package util
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"math/rand"
 	"time"
 )
@@ -16,7 +16,7 @@ func Retry(attempts int, sleep time.Duration, f func() error) error {
 		}
 
 		if attempts--; attempts > 0 {
-			log.Printf("retrying due to err: %v", err)
+			slog.Warn("retrying due to error", "err", err, "attempts_left", attempts)
 
 			jitter := time.Duration((rand.Int63n(int64(sleep))))
 			sleep += jitter / 2
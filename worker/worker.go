@@ -1,52 +1,77 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kencx/sxkcd/data"
-	"github.com/kencx/sxkcd/redis"
+	"github.com/kencx/sxkcd/metrics"
+	"github.com/kencx/sxkcd/store"
 	"github.com/kencx/sxkcd/util"
 )
 
+const (
+	latest   = 0
+	interval = 24 * time.Hour
+)
+
 type Worker struct {
-	rds    *redis.Client
+	store  store.Store
 	ticker *time.Ticker
 	stop   chan (bool)
-	busy   bool
+
+	// comics that are being fetched, including latest
+	fetchMu  sync.Mutex
+	fetching map[int]bool
+
+	// unix time of the worker start and last successful run
+	started     atomic.Int64
+	lastSuccess atomic.Int64
+
+	mu   sync.Mutex
+	subs map[chan data.Comic]struct{}
 }
 
-func New(client *redis.Client) *Worker {
+func New(st store.Store) *Worker {
 	return &Worker{
-		rds:    client,
-		ticker: time.NewTicker(24 * time.Hour),
-		stop:   make(chan bool),
-		busy:   false,
+		store:    st,
+		ticker:   time.NewTicker(interval),
+		stop:     make(chan bool),
+		fetching: make(map[int]bool),
+		subs:     make(map[chan data.Comic]struct{}),
 	}
 }
 
-func (w *Worker) Start() error {
-	log.Printf("starting worker...")
+// Start runs the worker in the background until Stop is called. Scheduled runs
+// use ctx.
+func (w *Worker) Start(ctx context.Context) error {
+	slog.Info("starting worker")
+	w.started.Store(time.Now().Unix())
 
 	go func() {
 		for {
 			select {
 			case <-w.stop:
 				w.ticker.Stop()
-				log.Println("stopping worker...")
+				slog.Info("stopping worker")
 				return
 			case <-w.ticker.C:
-				err := w.fetchComic()
+				fetchLatest := func() error { return w.fetchComic(ctx, latest) }
+
+				err := fetchLatest()
 				if err != nil {
-					log.Println(err)
+					slog.Warn("worker run failed", "err", err)
 
 					// sleep duration should not be longer than ticker duration
 					// signal interrupt will be blocked during sleep
-					err := util.Retry(3, 10*time.Second, w.fetchComic)
+					err := util.Retry(3, 10*time.Second, fetchLatest)
 					if err != nil {
-						log.Printf("worker: failed to retry, skipping run")
+						slog.Error("worker failed to retry, skipping run", "err", err)
 					}
 				}
 			}
@@ -57,44 +82,169 @@ func (w *Worker) Start() error {
 
 func (w *Worker) Stop() {
 	w.stop <- true
+
+	// close all subscriptions
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+// Subscribe returns a channel that receives every new comic added by the
+// worker. The channel is closed when the worker is stopped or the returned
+// cancel func is called.
+func (w *Worker) Subscribe() (<-chan data.Comic, func()) {
+	ch := make(chan data.Comic, 1)
+
+	w.mu.Lock()
+	w.subs[ch] = struct{}{}
+	w.mu.Unlock()
+
+	cancel := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish sends comic to all subscribers without blocking. Slow subscribers
+// miss the comic.
+func (w *Worker) publish(comic data.Comic) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subs {
+		select {
+		case ch <- comic:
+		default:
+		}
+	}
+}
+
+// Interval is the duration between scheduled runs
+func (w *Worker) Interval() time.Duration {
+	return interval
+}
+
+// Started returns the time the worker was started, or the zero time if it
+// was never started
+func (w *Worker) Started() time.Time {
+	return unixTime(w.started.Load())
+}
+
+// LastSuccess returns the time of the last successful run, or the zero time
+// if there was none
+func (w *Worker) LastSuccess() time.Time {
+	return unixTime(w.lastSuccess.Load())
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// FetchNow fetches the latest comic immediately, outside of the daily
+// schedule
+func (w *Worker) FetchNow(ctx context.Context) error {
+	return w.fetchComic(ctx, latest)
 }
 
-func (w *Worker) fetchComic() error {
-	if w.busy {
+// Refetch fetches comic num and replaces its existing document
+func (w *Worker) Refetch(ctx context.Context, num int) error {
+	if num <= latest {
+		return fmt.Errorf("worker: invalid comic number %d", num)
+	}
+	return w.fetchComic(ctx, num)
+}
+
+// fetchComic fetches the given comic and adds it to the index. If num is
+// latest, the changed fields of an existing comic are updated, otherwise any
+// existing document is replaced.
+func (w *Worker) fetchComic(ctx context.Context, num int) (err error) {
+	if !w.acquire(num) {
 		return fmt.Errorf("worker: fetching already in progress")
 	}
+	defer w.release(num)
 
-	w.busy = true
+	outcome := "added"
 	defer func() {
-		w.busy = false
+		if err != nil {
+			outcome = "failed"
+		} else {
+			w.lastSuccess.Store(time.Now().Unix())
+		}
+		metrics.WorkerRunsTotal.WithLabelValues(outcome).Inc()
 	}()
 
 	start := time.Now()
-	log.Println("worker: fetching latest comic")
+	if num == latest {
+		slog.Info("worker fetching latest comic")
+	} else {
+		slog.Info("worker fetching comic", "num", num)
+	}
 
 	client := data.NewClient()
-	latest, err := client.Fetch(0)
+	comic, err := client.Fetch(num)
 	if err != nil {
 		return err
 	}
 
-	exists, err := w.rds.ComicExists(latest.Number)
+	c, err := json.Marshal(&comic)
 	if err != nil {
-		return err
+		return fmt.Errorf("worker: failed to marshal comic: %w", err)
 	}
-	if exists {
-		log.Printf("worker: latest comic #%d already exists, skipping...", latest.Number)
+
+	if num != latest {
+		outcome = "replaced"
+		if err = w.store.Replace(ctx, comic.Number, c); err != nil {
+			return err
+		}
+		slog.Info("worker refetched comic", "num", comic.Number, "duration", time.Since(start))
 		return nil
 	}
 
-	c, err := json.Marshal(&latest)
+	exists, err := w.store.ComicExists(ctx, comic.Number)
 	if err != nil {
-		return fmt.Errorf("worker: failed to marshal comic: %w", err)
+		return err
 	}
-
-	if err = w.rds.Add(latest.Number, c); err != nil {
+	if err = w.store.Add(ctx, comic.Number, c); err != nil {
 		return err
 	}
-	log.Printf("worker: successfully fetched comic #%d in %v\n", latest.Number, time.Since(start))
+	if exists {
+		// explanations are often written after the comic is published
+		outcome = "updated"
+		slog.Info("worker updated existing comic", "num", comic.Number)
+		return nil
+	}
+	metrics.LatestComic.Set(float64(comic.Number))
+	w.publish(*comic)
+
+	slog.Info("worker fetched comic", "num", comic.Number, "duration", time.Since(start))
 	return nil
 }
+
+// acquire marks comic num as being fetched. It returns false if it is already
+// being fetched.
+func (w *Worker) acquire(num int) bool {
+	w.fetchMu.Lock()
+	defer w.fetchMu.Unlock()
+
+	if w.fetching[num] {
+		return false
+	}
+	w.fetching[num] = true
+	return true
+}
+
+func (w *Worker) release(num int) {
+	w.fetchMu.Lock()
+	defer w.fetchMu.Unlock()
+	delete(w.fetching, num)
+}